@@ -0,0 +1,294 @@
+package admin
+
+import (
+	"crypto/subtle"
+	"errors"
+	"jnet/log"
+	"jnet/network"
+	"net"
+	"net/http"
+	"net/http/pprof"
+	"strconv"
+	"strings"
+	"sync"
+
+	jsoniter "github.com/json-iterator/go"
+)
+
+var json = jsoniter.ConfigCompatibleWithStandardLibrary
+
+// ErrNoToken 未配置token时拒绝启动,避免运维接口对外开放
+var ErrNoToken = errors.New("admin: token required")
+
+// 未指定监听地址时只监听本机
+const defaultHost = "127.0.0.1"
+
+// SessionSource 连接列表来源,*tcp.Server实现了该接口
+type SessionSource interface {
+	Sessions() []network.SessionInfo
+	Kick(id uint64) bool
+	Handlers() []string
+}
+
+type Option func(s *Server)
+
+// WithToken 所有请求需携带"Authorization: Bearer <token>",未配置时拒绝所有请求
+func WithToken(token string) Option {
+	return func(s *Server) {
+		s.token = token
+	}
+}
+
+func WithSessions(src SessionSource) Option {
+	return func(s *Server) {
+		s.sessions = src
+	}
+}
+
+// WithLogger 允许通过/debug/log/level修改日志级别和模块级别
+func WithLogger(l *log.Logger) Option {
+	return func(s *Server) {
+		s.logger = l
+	}
+}
+
+// WithLogTail 通过/debug/log/tail?n=100查看最近的日志
+func WithLogTail(m *log.MemoryWriter) Option {
+	return func(s *Server) {
+		s.logTail = m
+	}
+}
+
+// WithStats 在/debug/stats中输出name对应的f()结果,如工作池状态
+func WithStats(name string, f func() interface{}) Option {
+	return func(s *Server) {
+		s.stats[name] = f
+	}
+}
+
+func WithPprof(enable bool) Option {
+	return func(s *Server) {
+		s.pprof = enable
+	}
+}
+
+// WithHandler 挂载额外的处理器,如metrics.Registry
+func WithHandler(pattern string, h http.Handler) Option {
+	return func(s *Server) {
+		s.handlers[pattern] = h
+	}
+}
+
+// Server 运维用的HTTP服务,查看连接、踢人、修改日志级别、pprof
+type Server struct {
+	addr     string
+	token    string
+	sessions SessionSource
+	logger   *log.Logger
+	logTail  *log.MemoryWriter
+	stats    map[string]func() interface{}
+	handlers map[string]http.Handler
+	pprof    bool
+	mux      *http.ServeMux
+	mutex    sync.Mutex
+	ln       net.Listener
+	srv      *http.Server
+}
+
+// NewServer addr为空或不含host时监听127.0.0.1,对外开放需显式指定如"0.0.0.0:6060"
+func NewServer(addr string, opts ...Option) *Server {
+	s := &Server{
+		addr:     listenAddr(addr),
+		stats:    make(map[string]func() interface{}),
+		handlers: make(map[string]http.Handler),
+		mux:      http.NewServeMux(),
+	}
+	for _, op := range opts {
+		op(s)
+	}
+	s.routes()
+	return s
+}
+
+func listenAddr(addr string) string {
+	if addr == "" {
+		return net.JoinHostPort(defaultHost, "0")
+	}
+	if host, port, err := net.SplitHostPort(addr); err == nil && host == "" {
+		return net.JoinHostPort(defaultHost, port)
+	}
+	return addr
+}
+
+func (s *Server) routes() {
+	if s.sessions != nil {
+		s.mux.HandleFunc("/debug/sessions", s.handleSessions)
+		s.mux.HandleFunc("/debug/sessions/kick", s.handleKick)
+		s.mux.HandleFunc("/debug/handlers", s.handleHandlers)
+	}
+	if s.logger != nil {
+		s.mux.HandleFunc("/debug/log/level", s.handleLogLevel)
+	}
+	if s.logTail != nil {
+		s.mux.HandleFunc("/debug/log/tail", s.handleLogTail)
+	}
+	s.mux.HandleFunc("/debug/stats", s.handleStats)
+	if s.pprof {
+		s.mux.HandleFunc("/debug/pprof/", pprof.Index)
+		s.mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+		s.mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+		s.mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+		s.mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	}
+	for pattern, h := range s.handlers {
+		s.mux.Handle(pattern, h)
+	}
+}
+
+// Handler 带token校验的处理器,可挂载到已有的http服务
+func (s *Server) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !s.authorized(r) {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		s.mux.ServeHTTP(w, r)
+	})
+}
+
+func (s *Server) authorized(r *http.Request) bool {
+	if s.token == "" {
+		return false
+	}
+	auth := r.Header.Get("Authorization")
+	if !strings.HasPrefix(auth, "Bearer ") {
+		return false
+	}
+	token := strings.TrimPrefix(auth, "Bearer ")
+	return subtle.ConstantTimeCompare([]byte(token), []byte(s.token)) == 1
+}
+
+// Serve 同步监听,成功后在后台处理请求,未配置token时返回ErrNoToken
+func (s *Server) Serve() error {
+	if s.token == "" {
+		return ErrNoToken
+	}
+	ln, err := net.Listen("tcp", s.addr)
+	if err != nil {
+		return err
+	}
+	srv := &http.Server{Handler: s.Handler()}
+	s.mutex.Lock()
+	s.ln = ln
+	s.srv = srv
+	s.mutex.Unlock()
+	go func() {
+		_ = srv.Serve(ln)
+	}()
+	return nil
+}
+
+func (s *Server) Addr() net.Addr {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.ln == nil {
+		return nil
+	}
+	return s.ln.Addr()
+}
+
+func (s *Server) Close() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.srv == nil {
+		return nil
+	}
+	return s.srv.Close()
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func (s *Server) handleSessions(w http.ResponseWriter, r *http.Request) {
+	sessions := s.sessions.Sessions()
+	if sessions == nil {
+		sessions = []network.SessionInfo{}
+	}
+	writeJSON(w, sessions)
+}
+
+func (s *Server) handleKick(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	id, err := strconv.ParseUint(r.URL.Query().Get("id"), 10, 64)
+	if err != nil {
+		http.Error(w, "invalid session id", http.StatusBadRequest)
+		return
+	}
+	if !s.sessions.Kick(id) {
+		http.Error(w, "session not found", http.StatusNotFound)
+		return
+	}
+	writeJSON(w, map[string]interface{}{"kicked": id})
+}
+
+func (s *Server) handleHandlers(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, s.sessions.Handlers())
+}
+
+func (s *Server) handleLogLevel(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPost, http.MethodPut:
+		// ?spec=network=debug,timer=warn 或 ?level=debug[&module=network]
+		query := r.URL.Query()
+		if spec := query.Get("spec"); spec != "" {
+			if err := s.logger.SetModuleLevels(spec); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			break
+		}
+		level, err := log.ParseLevel(query.Get("level"))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if module := query.Get("module"); module != "" {
+			s.logger.Module(module).SetLevel(level)
+		} else {
+			s.logger.SetLevel(level)
+		}
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	writeJSON(w, map[string]interface{}{
+		"level":   s.logger.GetLevel(),
+		"modules": s.logger.ModuleLevels(),
+	})
+}
+
+func (s *Server) handleLogTail(w http.ResponseWriter, r *http.Request) {
+	n := 0
+	if v := r.URL.Query().Get("n"); v != "" {
+		var err error
+		if n, err = strconv.Atoi(v); err != nil {
+			http.Error(w, "invalid n", http.StatusBadRequest)
+			return
+		}
+	}
+	writeJSON(w, s.logTail.Tail(n))
+}
+
+func (s *Server) handleStats(w http.ResponseWriter, r *http.Request) {
+	stats := make(map[string]interface{}, len(s.stats))
+	for name, f := range s.stats {
+		stats[name] = f()
+	}
+	writeJSON(w, stats)
+}
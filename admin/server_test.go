@@ -0,0 +1,156 @@
+package admin
+
+import (
+	"io/ioutil"
+	"jnet/log"
+	"jnet/network/base"
+	"jnet/network/tcp"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func doRequest(t *testing.T, srv *httptest.Server, method, path, token string) (int, string) {
+	req, err := http.NewRequest(method, srv.URL+path, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := srv.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, _ := ioutil.ReadAll(resp.Body)
+	return resp.StatusCode, string(body)
+}
+
+func TestAdminServer(t *testing.T) {
+	closed := make(chan base.CloseReason, 1)
+	tcpServer := tcp.NewServer("127.0.0.1:0")
+	tcpServer.BindPacketFunc(func(req base.IRequest) bool {
+		if req.GetMsgID() == base.SessionClose {
+			closed <- req.GetConnection().CloseReason()
+		}
+		return true
+	})
+	if err := tcpServer.Serve(); err != nil {
+		t.Fatal(err)
+	}
+	defer tcpServer.Close()
+	conn, err := net.Dial("tcp4", tcpServer.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	for len(tcpServer.Sessions()) == 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	logger, _ := log.NewLogger(log.WithDisableReportCaller(true))
+	defer logger.Close()
+	tail := log.NewMemoryWriter(10)
+	logger.AddWriter(tail)
+	a := NewServer("", WithToken("secret"), WithSessions(tcpServer), WithLogger(logger), WithLogTail(tail), WithPprof(true),
+		WithStats("pool", func() interface{} { return map[string]int{"workers": 4} }))
+	srv := httptest.NewServer(a.Handler())
+	defer srv.Close()
+
+	if code, _ := doRequest(t, srv, http.MethodGet, "/debug/sessions", ""); code != http.StatusUnauthorized {
+		t.Fatalf("request without token: status %d", code)
+	}
+	if code, _ := doRequest(t, srv, http.MethodGet, "/debug/sessions?token=wrong", ""); code != http.StatusUnauthorized {
+		t.Fatalf("request with wrong token: status %d", code)
+	}
+
+	var sessions []map[string]interface{}
+	code, body := doRequest(t, srv, http.MethodGet, "/debug/sessions", "secret")
+	if code != http.StatusOK || json.UnmarshalFromString(body, &sessions) != nil || len(sessions) != 1 {
+		t.Fatalf("sessions: %d %s", code, body)
+	}
+	if sessions[0]["remote_addr"] != conn.LocalAddr().String() {
+		t.Errorf("remote_addr = %v, want %v", sessions[0]["remote_addr"], conn.LocalAddr())
+	}
+
+	code, body = doRequest(t, srv, http.MethodPost, "/debug/log/level?level=debug", "secret")
+	if code != http.StatusOK || logger.GetLevel() != log.DebugLevel {
+		t.Fatalf("set level: %d %s, level %v", code, body, logger.GetLevel())
+	}
+	if code, _ = doRequest(t, srv, http.MethodPost, "/debug/log/level?level=verbose", "secret"); code != http.StatusBadRequest {
+		t.Fatalf("invalid level: status %d", code)
+	}
+	code, body = doRequest(t, srv, http.MethodPost, "/debug/log/level?spec=network=warn", "secret")
+	if code != http.StatusOK || body != `{"level":"debug","modules":{"network":"warn"}}`+"\n" {
+		t.Fatalf("set module level: %d %s", code, body)
+	}
+	logger.Module("network").Warn("slow client")
+	var lines []string
+	code, body = doRequest(t, srv, http.MethodGet, "/debug/log/tail?n=1", "secret")
+	if code != http.StatusOK || json.UnmarshalFromString(body, &lines) != nil || len(lines) != 1 ||
+		!strings.Contains(lines[0], "slow client module=network") {
+		t.Fatalf("log tail: %d %s", code, body)
+	}
+
+	code, body = doRequest(t, srv, http.MethodGet, "/debug/stats", "secret")
+	if code != http.StatusOK || body != `{"pool":{"workers":4}}`+"\n" {
+		t.Fatalf("stats: %d %s", code, body)
+	}
+	if code, _ = doRequest(t, srv, http.MethodGet, "/debug/pprof/", "secret"); code != http.StatusOK {
+		t.Fatalf("pprof: status %d", code)
+	}
+
+	id := strconv.FormatUint(uint64(sessions[0]["id"].(float64)), 10)
+	if code, _ = doRequest(t, srv, http.MethodPost, "/debug/sessions/kick?id=999", "secret"); code != http.StatusNotFound {
+		t.Fatalf("kick unknown session: status %d", code)
+	}
+	if code, body = doRequest(t, srv, http.MethodPost, "/debug/sessions/kick?id="+id, "secret"); code != http.StatusOK {
+		t.Fatalf("kick: %d %s", code, body)
+	}
+	select {
+	case r := <-closed:
+		if r != base.CloseKick {
+			t.Fatalf("close reason = %v, want %v", r, base.CloseKick)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("session not kicked")
+	}
+}
+
+func TestAdminServerRequiresToken(t *testing.T) {
+	a := NewServer("")
+	if err := a.Serve(); err != ErrNoToken {
+		t.Fatalf("Serve without token: %v, want %v", err, ErrNoToken)
+	}
+	srv := httptest.NewServer(a.Handler())
+	defer srv.Close()
+	if code, _ := doRequest(t, srv, http.MethodGet, "/debug/stats", "anything"); code != http.StatusUnauthorized {
+		t.Fatalf("request without configured token: status %d", code)
+	}
+
+	a = NewServer("", WithToken("secret"))
+	srv = httptest.NewServer(a.Handler())
+	defer srv.Close()
+	if code, _ := doRequest(t, srv, http.MethodGet, "/debug/stats?token=secret", ""); code != http.StatusUnauthorized {
+		t.Fatalf("query token: status %d", code)
+	}
+}
+
+func TestAdminServerLoopbackDefault(t *testing.T) {
+	for _, addr := range []string{"", ":0"} {
+		a := NewServer(addr, WithToken("secret"))
+		if err := a.Serve(); err != nil {
+			t.Fatal(err)
+		}
+		ip := a.Addr().(*net.TCPAddr).IP
+		a.Close()
+		if !ip.IsLoopback() {
+			t.Fatalf("NewServer(%q) listening on %v, want loopback", addr, ip)
+		}
+	}
+}
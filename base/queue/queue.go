@@ -2,7 +2,7 @@ package queue
 
 import (
 	"fmt"
-	"runtime"
+	"os"
 	"runtime/debug"
 	"sync"
 	"sync/atomic"
@@ -68,15 +68,30 @@ type eventQueue struct {
 	wakeUpChan   chan struct{}
 }
 
+// Logger 输出panic的日志接口,*log.Logger实现了该接口
+type Logger interface {
+	Errorf(template string, args ...interface{})
+}
+
+// LogPanic 输出到l的panic处理函数,用于SetPanicHandler,如queue.LogPanic(log.Module("queue"))
+func LogPanic(l Logger) func(interface{}) {
+	return func(i interface{}) {
+		l.Errorf("event queue panic: %v", i)
+	}
+}
+
+// printPanic 默认的panic处理函数,输出到标准错误,不依赖日志包
+func printPanic(i interface{}) {
+	fmt.Fprintf(os.Stderr, "event queue panic: %v\n", i)
+}
+
+// NewEventQueue 回调panic时将panic值和调用栈组成error交给panic处理函数,默认输出到标准错误
 func NewEventQueue() EventQueue {
 	return &eventQueue{
-		Queue: NewQueue(),
-		panicHandler: func(i interface{}) {
-			fmt.Printf("%v  \n%s\n", i, string(debug.Stack()))
-			debug.PrintStack()
-		},
-		exit:       make(chan struct{}),
-		wakeUpChan: make(chan struct{}),
+		Queue:        NewQueue(),
+		panicHandler: printPanic,
+		exit:         make(chan struct{}),
+		wakeUpChan:   make(chan struct{}),
 	}
 }
 
@@ -120,7 +135,7 @@ func (q *eventQueue) asyncDo(f func()) {
 func (q *eventQueue) Loop() bool {
 	defer func() {
 		if r := recover(); r != nil {
-			q.panicHandler(r)
+			q.panicHandler(fmt.Errorf("%v: %s", r, debug.Stack()))
 		}
 	}()
 	select {
@@ -135,10 +150,7 @@ func (q *eventQueue) Loop() bool {
 func (q *eventQueue) safeCall(callInfo *callInfo) {
 	defer func() {
 		if r := recover(); r != nil {
-			buf := make([]byte, 4096)
-			l := runtime.Stack(buf, false)
-			err := fmt.Errorf("%v: %v", r, string(buf[:l]))
-			q.panicHandler(err)
+			q.panicHandler(fmt.Errorf("%v: %s", r, debug.Stack()))
 		}
 	}()
 	if callInfo.cb != nil {
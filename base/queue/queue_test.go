@@ -0,0 +1,34 @@
+package queue
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+type errorfLogger struct {
+	lines chan string
+}
+
+func (l *errorfLogger) Errorf(template string, args ...interface{}) {
+	l.lines <- fmt.Sprintf(template, args...)
+}
+
+func TestEventQueuePanicStack(t *testing.T) {
+	logger := &errorfLogger{lines: make(chan string, 1)}
+	q := NewEventQueue()
+	q.SetPanicHandler(LogPanic(logger))
+	q.StartLoop()
+	defer q.Stop()
+
+	q.Post(func() { panic("boom") }, "test")
+	line := <-logger.lines
+	if !strings.Contains(line, "boom") || !strings.Contains(line, "queue_test.go") {
+		t.Fatalf("panic log without stack: %s", line)
+	}
+
+	// panic后继续处理之后的回调
+	done := make(chan struct{})
+	q.Post(func() { close(done) }, "test")
+	<-done
+}
@@ -0,0 +1,87 @@
+package ratelimit
+
+import (
+	"sync"
+	"time"
+)
+
+//令牌桶 rate: 每秒产生令牌数 capacity: 桶容量(允许的突发量)
+type Bucket struct {
+	mux      sync.Mutex
+	rate     float64
+	capacity float64
+	tokens   float64
+	last     time.Time
+	now      func() time.Time
+}
+
+func NewBucket(rate float64, capacity float64) *Bucket {
+	return NewBucketWithClock(rate, capacity, time.Now)
+}
+
+// NewBucketWithClock 使用now获取当前时间,测试中用于控制令牌补充
+func NewBucketWithClock(rate float64, capacity float64, now func() time.Time) *Bucket {
+	if capacity < 1 {
+		capacity = 1
+	}
+	return &Bucket{
+		rate:     rate,
+		capacity: capacity,
+		tokens:   capacity,
+		last:     now(),
+		now:      now,
+	}
+}
+
+func (b *Bucket) refill(now time.Time) {
+	elapsed := now.Sub(b.last).Seconds()
+	if elapsed <= 0 {
+		return
+	}
+	b.last = now
+	b.tokens += elapsed * b.rate
+	if b.tokens > b.capacity {
+		b.tokens = b.capacity
+	}
+}
+
+// Take 尝试取出n个令牌,不足时不消耗并返回false
+func (b *Bucket) Take(n float64) bool {
+	b.mux.Lock()
+	defer b.mux.Unlock()
+	b.refill(b.now())
+	if b.tokens < n {
+		return false
+	}
+	b.tokens -= n
+	return true
+}
+
+// Reserve 预占n个令牌,返回需要等待的时间,令牌允许透支
+func (b *Bucket) Reserve(n float64) time.Duration {
+	b.mux.Lock()
+	defer b.mux.Unlock()
+	b.refill(b.now())
+	b.tokens -= n
+	if b.tokens >= 0 || b.rate <= 0 {
+		return 0
+	}
+	return time.Duration(-b.tokens / b.rate * float64(time.Second))
+}
+
+// Cancel 归还Reserve预占但未使用的n个令牌
+func (b *Bucket) Cancel(n float64) {
+	b.mux.Lock()
+	defer b.mux.Unlock()
+	b.tokens += n
+	if b.tokens > b.capacity {
+		b.tokens = b.capacity
+	}
+}
+
+// Wait 阻塞直到取得n个令牌
+func (b *Bucket) Wait(n float64) {
+	if d := b.Reserve(n); d > 0 {
+		time.Sleep(d)
+	}
+}
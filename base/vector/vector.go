@@ -17,9 +17,8 @@ func NewVector() *Vector {
 func (v *Vector) insert(index int) {
 	if v.size == v.count {
 		v.resize(v.count + 1)
-	} else {
-		v.count++
 	}
+	v.count++
 	for i := v.count - 1; i > index; i-- {
 		v.arr[i] = v.arr[i-1]
 	}
@@ -35,9 +34,8 @@ func (v *Vector) resize(newCount int) {
 	}
 
 	v.size = blocks * VectorBlockSize
-	newArray := make([]interface{}, v.size+1)
-	count := copy(newArray, v.arr)
-	v.count = count
+	newArray := make([]interface{}, v.size)
+	copy(newArray, v.arr[:v.count])
 	v.arr = newArray
 }
 
@@ -48,10 +46,9 @@ func (v *Vector) PushFront(value interface{}) {
 func (v *Vector) PushBack(value interface{}) {
 	if v.count == v.size {
 		v.resize(v.count + 1)
-	} else {
-		v.count++
 	}
 	v.arr[v.count] = value
+	v.count++
 }
 
 func (v *Vector) Values() []interface{} {
@@ -13,3 +13,27 @@ func TestNewVector(t *testing.T) {
 	fmt.Println(v.Values())
 
 }
+
+// PushBack和PushFront交替时扩容前后数量不变
+func TestVectorMixedPush(t *testing.T) {
+	v := NewVector()
+	var want []int
+	for i := 0; i < 3*VectorBlockSize+5; i++ {
+		if i%3 == 0 {
+			v.PushFront(i)
+			want = append([]int{i}, want...)
+		} else {
+			v.PushBack(i)
+			want = append(want, i)
+		}
+	}
+	values := v.Values()
+	if len(values) != len(want) {
+		t.Fatalf("len %d, want %d", len(values), len(want))
+	}
+	for i := range want {
+		if values[i] != want[i] {
+			t.Fatalf("values %v, want %v", values, want)
+		}
+	}
+}
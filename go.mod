@@ -2,4 +2,4 @@ module jnet
 
 go 1.16
 
-require github.com/json-iterator/go v1.1.12 // indirect
+require github.com/json-iterator/go v1.1.12
@@ -0,0 +1,51 @@
+package log_test
+
+import (
+	"jnet/log"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+type lineWriter struct {
+	lines []string
+}
+
+func (w *lineWriter) Levels() []log.Level {
+	return log.AllLevels
+}
+
+func (w *lineWriter) LogWrite(b []byte) error {
+	w.lines = append(w.lines, string(b))
+	return nil
+}
+
+func (w *lineWriter) Close() {}
+
+func TestReportCaller(t *testing.T) {
+	l, _ := log.NewLogger()
+	w := new(lineWriter)
+	l.AddWriter(w)
+	l.Info("with caller")
+	l.WithField("k", "v").Warn("entry with caller")
+	l.WithFields(log.Fields{"k": "v"}).Infof("entry %s", "with caller")
+	if len(w.lines) != 3 {
+		t.Fatalf("got %q", w.lines)
+	}
+	for _, line := range w.lines {
+		if !strings.Contains(line, "caller_test.go:") {
+			t.Errorf("caller not reported: %q", line)
+		}
+	}
+}
+
+func TestCompactFormatter(t *testing.T) {
+	l, _ := log.NewLogger(log.WithFormatter(&log.CompactFormatter{}))
+	w := new(lineWriter)
+	l.AddWriter(w)
+	l.WithField("k", "v").Warn("compact")
+	re := regexp.MustCompile(`^\d\d:\d\d:\d\d\.\d{3} WARN  log/caller_test\.go:\d+ compact k=v\n$`)
+	if len(w.lines) != 1 || !re.MatchString(w.lines[0]) {
+		t.Fatalf("got %q", w.lines)
+	}
+}
@@ -0,0 +1,67 @@
+package log
+
+import (
+	"bytes"
+	"fmt"
+	"runtime"
+	"sort"
+	"strings"
+)
+
+// CompactFormatter 开发时使用的紧凑格式: 15:04:05.000 INFO  session/session.go:88 消息 key=value ...
+type CompactFormatter struct {
+	TimestampFormat string //默认TimeWithMillFormat
+	FullCaller      bool   //输出完整路径,默认只输出目录和文件名
+}
+
+func (f *CompactFormatter) Format(entry *Entry) ([]byte, error) {
+	b := entry.Buffer
+	if b == nil {
+		b = &bytes.Buffer{}
+	}
+	timestampFormat := f.TimestampFormat
+	if timestampFormat == "" {
+		timestampFormat = TimeWithMillFormat
+	}
+	b.WriteString(entry.Time.Format(timestampFormat))
+	b.WriteByte(' ')
+	name := "UNKNOWN"
+	if entry.Level.valid() {
+		name = strings.ToUpper(levelName[entry.Level])
+	}
+	fmt.Fprintf(b, "%-5s ", name)
+	if entry.HasCaller() {
+		if f.FullCaller {
+			fmt.Fprintf(b, "%s:%d ", entry.Caller.File, entry.Caller.Line)
+		} else {
+			b.WriteString(ShortCaller(entry.Caller))
+			b.WriteByte(' ')
+		}
+	}
+	b.WriteString(entry.Message)
+
+	keys := make([]string, 0, len(entry.Data))
+	for k := range entry.Data {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		b.WriteByte(' ')
+		b.WriteString(k)
+		b.WriteByte('=')
+		writeTextValue(b, entry.Data[k])
+	}
+	b.WriteByte('\n')
+	return b.Bytes(), nil
+}
+
+// ShortCaller 只保留最后一级目录和文件名,可作为CallerPrettyfier
+func ShortCaller(frame *runtime.Frame) string {
+	file := frame.File
+	if i := strings.LastIndexByte(file, '/'); i >= 0 {
+		if j := strings.LastIndexByte(file[:i], '/'); j >= 0 {
+			file = file[j+1:]
+		}
+	}
+	return fmt.Sprintf("%s:%d", file, frame.Line)
+}
@@ -1,6 +1,10 @@
 package log
 
-import "sync"
+import (
+	"fmt"
+	"strings"
+	"sync"
+)
 
 type (
 	Level int
@@ -33,15 +37,25 @@ var levelString = [...]string{
 	"[DEBUG]",
 }
 
+var levelName = [...]string{
+	"panic",
+	"fatal",
+	"error",
+	"warn",
+	"info",
+	"debug",
+}
+
 const (
 	SlashFormat         = "2006/01/02 15:04:05"
 	SlashWithMillFormat = "2006/01/02 15:04:05.000000"
 	DashFormat          = "2006-01-02 15:04:05"
 	DashWithMillFormat  = "2006-01-02 15:04:05.000000"
+	TimeWithMillFormat  = "15:04:05.000"
 )
 
 func (level Level) String() string {
-	if len(levelString) < int(level) {
+	if !level.valid() {
 		return "unknown"
 	}
 	return levelString[level]
@@ -50,6 +64,36 @@ func (level Level) valid() bool {
 	return level >= PanicLevel && level <= DebugLevel
 }
 
+// ParseLevel 解析级别名称,不区分大小写,兼容"[INFO]"形式
+func ParseLevel(s string) (Level, error) {
+	name := strings.ToLower(strings.Trim(strings.TrimSpace(s), "[]"))
+	if name == "warning" {
+		name = "warn"
+	}
+	for i, n := range levelName {
+		if n == name {
+			return Level(i), nil
+		}
+	}
+	return 0, fmt.Errorf("invalid log level: %q", s)
+}
+
+func (level Level) MarshalText() ([]byte, error) {
+	if !level.valid() {
+		return nil, fmt.Errorf("invalid log level: %d", level)
+	}
+	return []byte(levelName[level]), nil
+}
+
+func (level *Level) UnmarshalText(text []byte) error {
+	l, err := ParseLevel(string(text))
+	if err != nil {
+		return err
+	}
+	*level = l
+	return nil
+}
+
 type WgWrapper struct {
 	sync.WaitGroup
 }
@@ -0,0 +1,111 @@
+package log
+
+import (
+	"context"
+)
+
+// context中关联字段的名称
+const (
+	TraceIDKey   = "trace_id"
+	SessionIDKey = "session_id"
+	PlayerIDKey  = "player_id"
+)
+
+type ctxFieldsKey struct{}
+
+// ContextWithFields 在ctx中附加关联字段,*Ctx方法和Entry.WithContext会自动输出
+func ContextWithFields(ctx context.Context, fields Fields) context.Context {
+	old := FieldsFromContext(ctx)
+	data := make(Fields, len(old)+len(fields))
+	for k, v := range old {
+		data[k] = v
+	}
+	for k, v := range fields {
+		data[k] = v
+	}
+	return context.WithValue(ctx, ctxFieldsKey{}, data)
+}
+
+func ContextWithTraceID(ctx context.Context, id string) context.Context {
+	return ContextWithFields(ctx, Fields{TraceIDKey: id})
+}
+
+func ContextWithSessionID(ctx context.Context, id uint64) context.Context {
+	return ContextWithFields(ctx, Fields{SessionIDKey: id})
+}
+
+func ContextWithPlayerID(ctx context.Context, id interface{}) context.Context {
+	return ContextWithFields(ctx, Fields{PlayerIDKey: id})
+}
+
+// FieldsFromContext ctx中的关联字段,不可修改
+func FieldsFromContext(ctx context.Context) Fields {
+	if ctx == nil {
+		return nil
+	}
+	fields, _ := ctx.Value(ctxFieldsKey{}).(Fields)
+	return fields
+}
+
+// WithContext 附加ctx中的关联字段
+func (entry *Entry) WithContext(ctx context.Context) *Entry {
+	e := entry.WithFields(FieldsFromContext(ctx))
+	e.Context = ctx
+	return e
+}
+
+func (l *Logger) WithContext(ctx context.Context) *Entry {
+	return NewEntry(l).WithContext(ctx)
+}
+
+func (l *Logger) DebugCtx(ctx context.Context, args ...interface{}) {
+	l.WithContext(ctx).Debug(args...)
+}
+
+func (l *Logger) InfoCtx(ctx context.Context, args ...interface{}) {
+	l.WithContext(ctx).Info(args...)
+}
+
+func (l *Logger) WarnCtx(ctx context.Context, args ...interface{}) {
+	l.WithContext(ctx).Warn(args...)
+}
+
+func (l *Logger) ErrorCtx(ctx context.Context, args ...interface{}) {
+	l.WithContext(ctx).Error(args...)
+}
+
+func (l *Logger) DebugfCtx(ctx context.Context, format string, args ...interface{}) {
+	l.WithContext(ctx).Debugf(format, args...)
+}
+
+func (l *Logger) InfofCtx(ctx context.Context, format string, args ...interface{}) {
+	l.WithContext(ctx).Infof(format, args...)
+}
+
+func (l *Logger) WarnfCtx(ctx context.Context, format string, args ...interface{}) {
+	l.WithContext(ctx).Warnf(format, args...)
+}
+
+func (l *Logger) ErrorfCtx(ctx context.Context, format string, args ...interface{}) {
+	l.WithContext(ctx).Errorf(format, args...)
+}
+
+func WithContext(ctx context.Context) *Entry {
+	return defaultLogger.WithContext(ctx)
+}
+
+func DebugCtx(ctx context.Context, args ...interface{}) {
+	defaultLogger.WithContext(ctx).Debug(args...)
+}
+
+func InfoCtx(ctx context.Context, args ...interface{}) {
+	defaultLogger.WithContext(ctx).Info(args...)
+}
+
+func WarnCtx(ctx context.Context, args ...interface{}) {
+	defaultLogger.WithContext(ctx).Warn(args...)
+}
+
+func ErrorCtx(ctx context.Context, args ...interface{}) {
+	defaultLogger.WithContext(ctx).Error(args...)
+}
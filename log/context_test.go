@@ -0,0 +1,51 @@
+package log
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+type contextHook struct {
+	traceID string
+}
+
+func (h *contextHook) Levels() []Level {
+	return AllLevels
+}
+
+func (h *contextHook) Fire(entry *Entry) error {
+	h.traceID = FieldsFromContext(entry.Context)[TraceIDKey].(string)
+	return nil
+}
+
+func TestContextFields(t *testing.T) {
+	l, w := newCaptureLogger(t, WithDisableReportCaller(true))
+	hook := new(contextHook)
+	l.AddHook(hook)
+	ctx := ContextWithSessionID(context.Background(), 42)
+	ctx = ContextWithTraceID(ctx, "abc")
+	child := ContextWithPlayerID(ctx, 1001)
+
+	l.InfoCtx(ctx, "login")
+	l.WithField("zone", 3).WithContext(child).Warnf("kick %d", 1001)
+	if len(FieldsFromContext(ctx)) != 2 {
+		t.Fatalf("parent context modified: %v", FieldsFromContext(ctx))
+	}
+	lines := w.Lines()
+	want := []string{
+		"login session_id=42 trace_id=abc\n",
+		"kick 1001 player_id=1001 session_id=42 trace_id=abc zone=3\n",
+	}
+	if len(lines) != len(want) {
+		t.Fatalf("got %q", lines)
+	}
+	for i := range want {
+		if !strings.HasSuffix(lines[i], want[i]) {
+			t.Fatalf("line %d = %q, want suffix %q", i, lines[i], want[i])
+		}
+	}
+	if hook.traceID != "abc" {
+		t.Fatalf("hook saw trace id %q", hook.traceID)
+	}
+}
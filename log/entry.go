@@ -2,6 +2,7 @@ package log
 
 import (
 	"bytes"
+	"context"
 	"fmt"
 	"os"
 	"runtime"
@@ -18,7 +19,7 @@ var (
 
 const (
 	maximumCallerDepth int = 25
-	knownLogrusFrames  int = 4
+	knownLogFrames     int = 4
 )
 
 func init() {
@@ -33,13 +34,20 @@ type Entry struct {
 	Message string
 	Caller  *runtime.Frame
 	Data    Fields
+	Context context.Context //WithContext传入的ctx,供Hook使用
+
+	noSample bool //采样汇总等内部日志不参与采样
 }
 
 func NewEntry(logger *Logger) *Entry {
-	return &Entry{
+	entry := &Entry{
 		Logger: logger,
 		Data:   make(Fields, 6),
 	}
+	if logger.module != "" {
+		entry.Data[ModuleKey] = logger.module
+	}
+	return entry
 }
 
 func (entry *Entry) Dup() *Entry {
@@ -47,16 +55,16 @@ func (entry *Entry) Dup() *Entry {
 	for k, v := range entry.Data {
 		data[k] = v
 	}
-	return &Entry{Logger: entry.Logger, Data: data, Time: entry.Time}
+	return &Entry{Logger: entry.Logger, Data: data, Time: entry.Time, Context: entry.Context, noSample: entry.noSample}
 }
 
 func (entry *Entry) Bytes() ([]byte, error) {
-	return entry.Logger.Formatter.Format(entry)
+	return entry.Logger.core().Formatter.Format(entry)
 }
 
 func (entry Entry) HasCaller() (has bool) {
 	return entry.Logger != nil &&
-		entry.Logger.ReportCaller &&
+		entry.Logger.core().ReportCaller &&
 		entry.Caller != nil
 }
 
@@ -72,7 +80,7 @@ func (entry *Entry) WithFields(fields Fields) *Entry {
 	for k, v := range fields {
 		data[k] = v
 	}
-	return &Entry{Logger: entry.Logger, Data: data, Time: entry.Time}
+	return &Entry{Logger: entry.Logger, Data: data, Time: entry.Time, Context: entry.Context, noSample: entry.noSample}
 }
 
 func (entry *Entry) WithTime(t time.Time) *Entry {
@@ -80,7 +88,7 @@ func (entry *Entry) WithTime(t time.Time) *Entry {
 	for k, v := range entry.Data {
 		dataCopy[k] = v
 	}
-	return &Entry{Logger: entry.Logger, Data: dataCopy, Time: t}
+	return &Entry{Logger: entry.Logger, Data: dataCopy, Time: t, Context: entry.Context}
 }
 
 func (entry *Entry) log(level Level, msg string) {
@@ -95,30 +103,24 @@ func (entry *Entry) log(level Level, msg string) {
 	newEntry.Level = level
 	newEntry.Message = msg
 
-	newEntry.Logger.mux.Lock()
-	bufPool := newEntry.Logger.BufferPool
-	newEntry.Logger.mux.Unlock()
-
-	if newEntry.Logger.ReportCaller {
+	if newEntry.Logger.core().ReportCaller {
 		newEntry.Caller = getCaller()
 	}
+	if s := newEntry.Logger.core().sampler; s != nil && !newEntry.noSample && !s.allow(newEntry) {
+		return
+	}
 	newEntry.fireHooks()
-	buffer = bufPool.Get()
+	buffer = bufferPool.Get()
 	defer func() {
 		newEntry.Buffer = nil
-		buffer.Reset()
-		bufPool.Put(buffer)
+		bufferPool.Put(buffer)
 	}()
 	buffer.Reset()
 	newEntry.Buffer = buffer
 
 	newEntry.write()
-
-	newEntry.Buffer = nil
-	if level <= PanicLevel {
-		panic(entry)
-	}
 }
+
 func (entry *Entry) Log(level Level, args ...interface{}) {
 	if entry.Logger.IsLevelEnabled(level) {
 		entry.log(level, fmt.Sprint(args...))
@@ -150,7 +152,7 @@ func getCaller() *runtime.Frame {
 				break
 			}
 		}
-		minimumCallerDepth = knownLogrusFrames
+		minimumCallerDepth = knownLogFrames
 	})
 	pcs := make([]uintptr, maximumCallerDepth)
 	depth := runtime.Callers(minimumCallerDepth, pcs)
@@ -169,12 +171,13 @@ func getCaller() *runtime.Frame {
 
 func (entry *Entry) fireHooks() {
 	var tmpHooks LevelHooks
-	entry.Logger.mux.Lock()
-	tmpHooks = make(LevelHooks, len(entry.Logger.Hooks))
-	for k, v := range entry.Logger.Hooks {
+	core := entry.Logger.core()
+	core.mux.Lock()
+	tmpHooks = make(LevelHooks, len(core.Hooks))
+	for k, v := range core.Hooks {
 		tmpHooks[k] = v
 	}
-	entry.Logger.mux.Unlock()
+	core.mux.Unlock()
 	err := tmpHooks.Fire(entry.Level, entry)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Failed to fire hook: %v\n", err)
@@ -182,21 +185,17 @@ func (entry *Entry) fireHooks() {
 }
 
 func (entry *Entry) write() {
-	entry.Logger.mux.Lock()
-	defer entry.Logger.mux.Unlock()
-	serialized, err := entry.Logger.Formatter.Format(entry)
+	core := entry.Logger.core()
+	core.mux.Lock()
+	formatter := core.Formatter
+	core.mux.Unlock()
+	serialized, err := formatter.Format(entry)
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Failed to obtain reader, %v\n", err)
+		fmt.Fprintf(os.Stderr, "Failed to format entry, %v\n", err)
 		return
 	}
-	if _, err := entry.Logger.Out.Write(serialized); err != nil {
-		fmt.Fprintf(os.Stderr, "Failed to write to log, %v\n", err)
-	}
-
-}
-
-func (entry *Entry) Trace(args ...interface{}) {
-	entry.Log(TraceLevel, args...)
+	//写协程异步输出,不能引用池中的buffer
+	entry.Logger.fireWrite(entry.Level, Copy(serialized))
 }
 
 func (entry *Entry) Debug(args ...interface{}) {
@@ -225,22 +224,26 @@ func (entry *Entry) Error(args ...interface{}) {
 
 func (entry *Entry) Fatal(args ...interface{}) {
 	entry.Log(FatalLevel, args...)
-	os.Exit(1)
+	entry.Logger.exit()
 }
 
 func (entry *Entry) Panic(args ...interface{}) {
-	entry.Log(PanicLevel, args...)
+	entry.panic(fmt.Sprint(args...))
 }
 
-func (entry *Entry) Logf(level Level, format string, args ...interface{}) {
-	if entry.Logger.IsLevelEnabled(level) {
-		entry.Log(level, fmt.Sprintf(format, args...))
+// panic 写出日志后以消息内容panic,PanicLevel未开启时也会panic
+func (entry *Entry) panic(msg string) {
+	if entry.Logger.IsLevelEnabled(PanicLevel) {
+		entry.log(PanicLevel, msg)
+		entry.Logger.sync()
 	}
-
+	panic(msg)
 }
 
-func (entry *Entry) Tracef(format string, args ...interface{}) {
-	entry.Logf(TraceLevel, format, args...)
+func (entry *Entry) Logf(level Level, format string, args ...interface{}) {
+	if entry.Logger.IsLevelEnabled(level) {
+		entry.log(level, fmt.Sprintf(format, args...))
+	}
 }
 
 func (entry *Entry) Debugf(format string, args ...interface{}) {
@@ -269,11 +272,11 @@ func (entry *Entry) Errorf(format string, args ...interface{}) {
 
 func (entry *Entry) Fatalf(format string, args ...interface{}) {
 	entry.Logf(FatalLevel, format, args...)
-	os.Exit(1)
+	entry.Logger.exit()
 }
 
 func (entry *Entry) Panicf(format string, args ...interface{}) {
-	entry.Logf(PanicLevel, format, args...)
+	entry.panic(fmt.Sprintf(format, args...))
 }
 
 func (entry *Entry) sprintlnn(args ...interface{}) string {
@@ -283,14 +286,10 @@ func (entry *Entry) sprintlnn(args ...interface{}) string {
 
 func (entry *Entry) Logln(level Level, args ...interface{}) {
 	if entry.Logger.IsLevelEnabled(level) {
-		entry.Log(level, entry.sprintlnn(args...))
+		entry.log(level, entry.sprintlnn(args...))
 	}
 }
 
-func (entry *Entry) Traceln(args ...interface{}) {
-	entry.Logln(TraceLevel, args...)
-}
-
 func (entry *Entry) Debugln(args ...interface{}) {
 	entry.Logln(DebugLevel, args...)
 }
@@ -317,9 +316,9 @@ func (entry *Entry) Errorln(args ...interface{}) {
 
 func (entry *Entry) Fatalln(args ...interface{}) {
 	entry.Logln(FatalLevel, args...)
-	os.Exit(1)
+	entry.Logger.exit()
 }
 
 func (entry *Entry) Panicln(args ...interface{}) {
-	entry.Logln(PanicLevel, args...)
+	entry.panic(entry.sprintlnn(args...))
 }
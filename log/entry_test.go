@@ -0,0 +1,132 @@
+package log
+
+import (
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// 同步写入内存,便于断言输出
+type captureWriter struct {
+	mux    sync.Mutex
+	levels []Level
+	lines  []string
+}
+
+func (c *captureWriter) Levels() []Level {
+	if c.levels == nil {
+		return AllLevels
+	}
+	return c.levels
+}
+
+func (c *captureWriter) LogWrite(b []byte) error {
+	c.mux.Lock()
+	c.lines = append(c.lines, string(b))
+	c.mux.Unlock()
+	return nil
+}
+
+func (c *captureWriter) Close() {}
+
+func (c *captureWriter) Lines() []string {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	return append([]string(nil), c.lines...)
+}
+
+func newCaptureLogger(t *testing.T, opts ...Option) (*Logger, *captureWriter) {
+	l, err := NewLogger(opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := new(captureWriter)
+	l.AddWriter(w)
+	return l, w
+}
+
+func TestTextFormatterFields(t *testing.T) {
+	l, w := newCaptureLogger(t, WithName("game"), WithDisableReportCaller(true))
+	ts := time.Date(2021, 3, 4, 5, 6, 7, 8000, time.UTC)
+	l.WithFields(Fields{"session": 12, "remote": "1.2.3.4:5"}).WithTime(ts).Info("hello world")
+	l.WithError(errors.New("boom")).WithTime(ts).Debug("filtered")
+	l.WithError(errors.New("boom")).WithTime(ts).Errorf("failed %d", 3)
+
+	lines := w.Lines()
+	want := []string{
+		"[game] [INFO][2021/03/04 05:06:07.000008] hello world remote=1.2.3.4:5 session=12\n",
+		"[game] [ERROR][2021/03/04 05:06:07.000008] failed 3 error=boom\n",
+	}
+	if len(lines) != len(want) {
+		t.Fatalf("got %q", lines)
+	}
+	for i := range want {
+		if lines[i] != want[i] {
+			t.Errorf("line %d = %q, want %q", i, lines[i], want[i])
+		}
+	}
+}
+
+func TestJSONFormatter(t *testing.T) {
+	l, w := newCaptureLogger(t, WithDisableReportCaller(true), WithFormatter(&JSONFormatter{
+		FieldMap: FieldMap{FieldKeyMsg: "message", FieldKeyTime: "@timestamp"},
+	}))
+	ts := time.Date(2021, 3, 4, 5, 6, 7, 0, time.UTC)
+	l.WithFields(Fields{"level": "clash", "player": 7}).WithTime(ts).Warn("moved")
+
+	lines := w.Lines()
+	if len(lines) != 1 {
+		t.Fatalf("got %q", lines)
+	}
+	var got map[string]interface{}
+	if err := json.UnmarshalFromString(lines[0], &got); err != nil {
+		t.Fatalf("invalid json %q: %v", lines[0], err)
+	}
+	want := map[string]interface{}{
+		"message":      "moved",
+		"@timestamp":   "2021-03-04T05:06:07Z",
+		"level":        "warn",
+		"fields.level": "clash",
+		"player":       float64(7),
+	}
+	for k, v := range want {
+		if got[k] != v {
+			t.Errorf("%s = %v, want %v", k, got[k], v)
+		}
+	}
+	if len(got) != len(want) {
+		t.Errorf("unexpected keys in %v", got)
+	}
+}
+
+type testHook struct {
+	levels []Level
+	fired  []string
+}
+
+func (h *testHook) Levels() []Level {
+	return h.levels
+}
+
+func (h *testHook) Fire(entry *Entry) error {
+	h.fired = append(h.fired, entry.Message)
+	entry.Data["hooked"] = true
+	return nil
+}
+
+func TestLevelHooks(t *testing.T) {
+	l, w := newCaptureLogger(t, WithDisableReportCaller(true))
+	hook := &testHook{levels: []Level{ErrorLevel}}
+	l.AddHook(hook)
+	l.Info("info")
+	l.Error("error")
+	if len(hook.fired) != 1 || hook.fired[0] != "error" {
+		t.Fatalf("hook fired for %q", hook.fired)
+	}
+	lines := w.Lines()
+	if len(lines) != 2 || strings.Contains(lines[0], "hooked") || !strings.Contains(lines[1], "hooked=true") {
+		t.Fatalf("hook fields not applied: %q", lines)
+	}
+}
@@ -0,0 +1,10 @@
+package log
+
+// ErrorKey WithError使用的字段名
+var ErrorKey = "error"
+
+// ModuleKey 模块Logger附加的字段名
+var ModuleKey = "module"
+
+// Fields 结构化日志的附加字段
+type Fields map[string]interface{}
@@ -1,55 +1,98 @@
 package log
 
 import (
+	"bufio"
+	"compress/gzip"
 	"fmt"
-	"io/fs"
+	"io"
 	"jnet/log/fileutil"
 	"os"
-	"path"
 	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
 	"sync"
 	"sync/atomic"
 	"time"
 )
 
+// RotatePeriod 按时间切分日志文件的周期
+type RotatePeriod int
+
+const (
+	RotateNone   RotatePeriod = iota // 只按大小切分
+	RotateHourly                     // 每小时切分
+	RotateDaily                      // 每天0点切分
+)
+
+const (
+	stampFormat        = "20060102-15" // 文件名中的时间段,name.YYYYMMDD-HH.N.log
+	retentionCheckTime = 30 * time.Second
+)
+
+// WithRotate 按时间切分日志文件,默认只按大小切分
+func WithRotate(period RotatePeriod) WriterOption {
+	return func(o *writerOptions) {
+		o.rotate = period
+	}
+}
+
+// WithCompress 切分后在后台将旧文件压缩为.gz
+func WithCompress(b bool) WriterOption {
+	return func(o *writerOptions) {
+		o.compress = b
+	}
+}
+
+// WithFlushSize 文件写缓冲区大小,缓冲区满时写出,默认64KB,<=0每行写出
+func WithFlushSize(n int) WriterOption {
+	return func(o *writerOptions) {
+		o.flushSize = n
+	}
+}
+
+// WithMaxBackups 最多保留的旧文件个数,<=0不限制
+func WithMaxBackups(n int) WriterOption {
+	return func(o *writerOptions) {
+		o.maxBackups = n
+	}
+}
+
 type FileWriter struct {
-	closed      int32         // writer是否已关闭
 	filePath    string        // 文件保存路径
-	fileName    string        // 文件名
+	fileName    string        // 文件名,指向当前文件的软链接
+	baseName    string        // 去掉扩展名的文件名
 	maxSize     int64         // 文件大小上限
 	maxSaveTime time.Duration // 文件保存最长时间
 	currentSize int64         // 当前文件大小（记录当前已经写入的字节数）
 	file        *os.File      // 文件句柄
-	writeBuffer chan []byte   // 写缓存
+	buf         *bufio.Writer // 文件写缓冲,Error及以上级别立即写出
+	stamp       string        // 当前文件的时间段
+	nextRotate  time.Time     // 下次按时间切分的时间,RotateNone时为零值
+	active      atomic.Value  // 当前文件名,清理时跳过
+	rotated     chan struct{} // 有新的旧文件时通知后台压缩和清理
+	pendingMux  sync.Mutex
+	pending     []string // 切分出的旧文件,写协程不等待后台处理
+	*lineQueue           // 写缓存
 	levels      []Level
-	exit        chan struct{}
-	WgWrapper
-	sync.Once
 }
 
-func NewFileWriter(filePath, fileName string, maxSize int64, maxSaveTime time.Duration) (*FileWriter, error) {
+func NewFileWriter(filePath, fileName string, maxSize int64, maxSaveTime time.Duration, opts ...WriterOption) (*FileWriter, error) {
 	if err := fileutil.NewPath(filePath); err != nil {
 		return nil, err
 	}
-	name := path.Join(filePath, fileName)
-	file, err := os.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
-	if err != nil {
-		return nil, err
-	}
-	stat, err := os.Stat(name)
-	if err != nil {
-		return nil, err
-	}
 	f := &FileWriter{
 		filePath:    filePath,
 		fileName:    fileName,
+		baseName:    strings.TrimSuffix(fileName, filepath.Ext(fileName)),
 		maxSize:     maxSize,
 		maxSaveTime: maxSaveTime,
-		file:        file,
-		writeBuffer: make(chan []byte, 1<<10),
+		rotated:     make(chan struct{}, 1),
+		lineQueue:   newLineQueue("file", opts),
 		levels:      AllLevels,
-		currentSize: stat.Size(),
-		exit:        make(chan struct{}),
+	}
+	if err := f.openFile(f.opt.now()); err != nil {
+		return nil, err
 	}
 	f.Start()
 	return f, nil
@@ -60,89 +103,279 @@ func (f *FileWriter) SetLevels(levels []Level) {
 }
 
 func (f *FileWriter) Start() {
-	f.WgWrapper.Wrap(func() {
-		var ticker *time.Ticker
-		var duration = f.checkLife()
-		if duration > 0 {
-			ticker = time.NewTicker(duration)
-		}
-		for {
-			select {
-			case content := <-f.writeBuffer:
-				f.writeToFile(content)
-				f.rotate()
-			case <-f.exit:
-				if ticker != nil {
-					ticker.Stop()
-				}
-				return
-			}
-			if ticker != nil {
-				select {
-				case <-ticker.C:
-					duration = f.checkLife()
-					ticker.Reset(duration)
-				default:
-					break
-				}
-			}
-		}
-	})
+	f.start(f)
+	f.Wrap(f.maintain)
 }
 
-func (f *FileWriter) rotate() {
-	if f.maxSize <= 0 || f.currentSize < f.maxSize {
-		return
+func (f *FileWriter) writeLine(level Level, b []byte) {
+	now := f.opt.now()
+	if !f.nextRotate.IsZero() && !now.Before(f.nextRotate) {
+		f.rotate(now)
+	}
+	f.writeToFile(b)
+	if level <= ErrorLevel || f.opt.flushSize <= 0 {
+		f.flush()
 	}
+	if f.maxSize > 0 && f.currentSize >= f.maxSize {
+		f.rotate(now)
+	}
+}
 
-	_ = f.file.Sync()
+func (f *FileWriter) flush() {
+	if err := f.buf.Flush(); err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to write log file, %v\n", err)
+		f.buf.Reset(f.file) // 丢弃写失败的缓冲,避免之后一直报错
+	}
+}
 
-	_ = f.file.Close()
+func (f *FileWriter) periodStart(now time.Time) time.Time {
+	y, m, d := now.Date()
+	if f.opt.rotate == RotateDaily {
+		return time.Date(y, m, d, 0, 0, 0, 0, now.Location())
+	}
+	return time.Date(y, m, d, now.Hour(), 0, 0, 0, now.Location())
+}
+
+func (f *FileWriter) backupName(stamp string, seq int) string {
+	return fmt.Sprintf("%s.%s.%d.log", f.baseName, stamp, seq)
+}
 
-	// 重命名
-	nowTime := time.Now()
-	oldName := f.file.Name()
-	newFileName := fmt.Sprintf("%s.log", nowTime.Format("2006_01_02_15_04_05"))
-	newName := path.Join(f.filePath, newFileName)
-	err := os.Rename(oldName, newName)
+// parseBackup 解析name.YYYYMMDD-HH.N.log[.gz]
+func (f *FileWriter) parseBackup(name string) (stamp string, seq int, ok bool) {
+	if name == f.fileName || !strings.HasPrefix(name, f.baseName+".") {
+		return "", 0, false
+	}
+	s := strings.TrimPrefix(name, f.baseName+".")
+	s = strings.TrimSuffix(s, ".gz")
+	if !strings.HasSuffix(s, ".log") {
+		return "", 0, false
+	}
+	s = strings.TrimSuffix(s, ".log")
+	i := strings.LastIndexByte(s, '.')
+	if i < 0 {
+		return "", 0, false
+	}
+	if _, err := time.Parse(stampFormat, s[:i]); err != nil {
+		return "", 0, false
+	}
+	seq, err := strconv.Atoi(s[i+1:])
+	if err != nil || seq < 0 {
+		return "", 0, false
+	}
+	return s[:i], seq, true
+}
+
+// 同一时间段已存在的最大序号,不存在时为-1
+func (f *FileWriter) lastSeq(stamp string) int {
+	last := -1
+	entries, _ := os.ReadDir(f.filePath)
+	for _, e := range entries {
+		if s, seq, ok := f.parseBackup(e.Name()); ok && s == stamp && seq > last {
+			last = seq
+		}
+	}
+	return last
+}
+
+// openFile 打开当前时间段的文件,重启后继续写入未满的文件
+func (f *FileWriter) openFile(now time.Time) error {
+	period := f.periodStart(now)
+	stamp := period.Format(stampFormat)
+	seq := f.lastSeq(stamp)
+	if seq < 0 {
+		seq = 0
+	} else if info, err := os.Stat(filepath.Join(f.filePath, f.backupName(stamp, seq))); err != nil ||
+		(f.maxSize > 0 && info.Size() >= f.maxSize) {
+		seq++
+	}
+	name := f.backupName(stamp, seq)
+	file, err := os.OpenFile(filepath.Join(f.filePath, name), os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
 	if err != nil {
-		fmt.Sprintln(err)
+		_ = file.Close()
+		return err
 	}
+	f.file = file
+	if f.opt.flushSize > 0 {
+		f.buf = bufio.NewWriterSize(file, f.opt.flushSize)
+	} else {
+		f.buf = bufio.NewWriter(file)
+	}
+	f.currentSize = info.Size()
+	f.stamp = stamp
+	f.active.Store(name)
+	switch f.opt.rotate {
+	case RotateHourly:
+		f.nextRotate = period.Add(time.Hour)
+	case RotateDaily:
+		f.nextRotate = period.AddDate(0, 0, 1)
+	}
+	f.link(name)
+	return nil
+}
+
+// link 原子地将fileName指向当前文件,fileName是旧版本遗留的普通文件时不处理
+func (f *FileWriter) link(target string) {
+	link := filepath.Join(f.filePath, f.fileName)
+	if info, err := os.Lstat(link); err == nil && info.Mode()&os.ModeSymlink == 0 {
+		return
+	}
+	tmp := link + ".tmp"
+	_ = os.Remove(tmp)
+	if err := os.Symlink(target, tmp); err != nil {
+		return
+	}
+	if err := os.Rename(tmp, link); err != nil {
+		_ = os.Remove(tmp)
+	}
+}
+
+func (f *FileWriter) rotate(now time.Time) {
+	old := f.file
+	f.flush()
+	_ = old.Sync()
+	if err := f.openFile(now); err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to rotate log file, %v\n", err)
+		return
+	}
+	_ = old.Close()
+	f.pendingMux.Lock()
+	f.pending = append(f.pending, old.Name())
+	f.pendingMux.Unlock()
+	select {
+	case f.rotated <- struct{}{}:
+	default:
+	}
+}
 
-	// 重置size和句柄
-	f.currentSize = 0
-	f.file, _ = os.OpenFile(oldName, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+// archivePending 处理切分出的旧文件
+func (f *FileWriter) archivePending() {
+	f.pendingMux.Lock()
+	names := f.pending
+	f.pending = nil
+	f.pendingMux.Unlock()
+	for _, name := range names {
+		f.archive(name)
+	}
 }
+
 func (f *FileWriter) writeToFile(msg ...[]byte) {
 	for _, m := range msg {
-		n, _ := f.file.Write(m)
+		n, _ := f.buf.Write(m)
 		f.currentSize += int64(n)
 	}
 }
 
-func (f *FileWriter) checkLife() time.Duration {
-	if f.maxSaveTime <= 0 {
-		return 0
+// maintain 后台压缩和清理旧文件,写协程退出后处理完剩余的旧文件再退出
+func (f *FileWriter) maintain() {
+	var tick <-chan time.Time
+	if f.maxSaveTime > 0 {
+		ticker := time.NewTicker(retentionCheckTime)
+		defer ticker.Stop()
+		tick = ticker.C
 	}
-	const duration = 30 * time.Second
-	_ = filepath.Walk(f.filePath, func(path string, info fs.FileInfo, err error) error {
-		if info.IsDir() {
-			return nil
+	f.checkLife()
+	for {
+		select {
+		case <-f.rotated:
+			f.archivePending()
+			f.checkLife()
+		case <-tick:
+			f.checkLife()
+		case <-f.stopped:
+			f.archivePending()
+			f.checkLife()
+			return
 		}
+	}
+}
 
-		if filepath.Ext(path) != ".log" || info.Name() == f.fileName {
-			return nil
-		}
+func (f *FileWriter) archive(name string) {
+	if !f.opt.compress {
+		return
+	}
+	if err := gzipFile(name); err != nil && !os.IsNotExist(err) {
+		fmt.Fprintf(os.Stderr, "Failed to compress log file, %v\n", err)
+	}
+}
+
+// gzipFile 压缩为name.gz并删除原文件,保留修改时间用于按时间清理
+func gzipFile(name string) error {
+	src, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	info, err := src.Stat()
+	if err != nil {
+		return err
+	}
+	dst, err := os.OpenFile(name+".gz", os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	zw := gzip.NewWriter(dst)
+	if _, err = io.Copy(zw, src); err == nil {
+		err = zw.Close()
+	}
+	if cErr := dst.Close(); err == nil {
+		err = cErr
+	}
+	if err != nil {
+		_ = os.Remove(name + ".gz")
+		return err
+	}
+	_ = os.Chtimes(name+".gz", info.ModTime(), info.ModTime())
+	return os.Remove(name)
+}
 
-		if info.Size() < f.maxSize {
-			return nil
+// checkLife 删除超过保存时间的旧文件,并只保留最新的maxBackups个
+func (f *FileWriter) checkLife() {
+	entries, err := os.ReadDir(f.filePath)
+	if err != nil {
+		return
+	}
+	type backup struct {
+		name  string
+		stamp string
+		seq   int
+	}
+	active, _ := f.active.Load().(string)
+	now := time.Now()
+	var backups []backup
+	for _, e := range entries {
+		if e.IsDir() || e.Name() == active {
+			continue
+		}
+		stamp, seq, ok := f.parseBackup(e.Name())
+		if !ok {
+			continue
 		}
-		if time.Now().Sub(info.ModTime()) < f.maxSaveTime {
-			return nil
+		path := filepath.Join(f.filePath, e.Name())
+		if f.maxSaveTime > 0 {
+			if info, err := e.Info(); err == nil && now.Sub(info.ModTime()) > f.maxSaveTime {
+				_ = os.Remove(path)
+				continue
+			}
 		}
-		return os.Remove(path)
+		backups = append(backups, backup{name: path, stamp: stamp, seq: seq})
+	}
+	if f.opt.maxBackups <= 0 || len(backups) <= f.opt.maxBackups {
+		return
+	}
+	// 按文件名中的时间段和序号从新到旧排序
+	sort.Slice(backups, func(i, j int) bool {
+		if backups[i].stamp != backups[j].stamp {
+			return backups[i].stamp > backups[j].stamp
+		}
+		return backups[i].seq > backups[j].seq
 	})
-	return duration
+	for _, b := range backups[f.opt.maxBackups:] {
+		_ = os.Remove(b.name)
+	}
 }
 
 func (f *FileWriter) Levels() []Level {
@@ -150,16 +383,18 @@ func (f *FileWriter) Levels() []Level {
 }
 
 func (f *FileWriter) LogWrite(b []byte) error {
-	if atomic.LoadInt32(&f.closed) == 1 {
-		return nil
-	}
-	f.writeBuffer <- b
+	f.push(InfoLevel, b)
 	return nil
 }
+
+func (f *FileWriter) LogWriteLevel(level Level, b []byte) error {
+	f.push(level, b)
+	return nil
+}
+
+// Close 写完缓存中的日志后关闭文件
 func (f *FileWriter) Close() {
-	f.Do(func() {
-		atomic.StoreInt32(&f.closed, 1)
-		f.exit <- struct{}{}
-		f.Wait()
-	})
+	f.close()
+	_ = f.file.Sync()
+	_ = f.file.Close()
 }
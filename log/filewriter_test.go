@@ -0,0 +1,209 @@
+package log
+
+import (
+	"compress/gzip"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type fakeNow struct {
+	t int64
+}
+
+func (c *fakeNow) now() time.Time {
+	return time.Unix(0, atomic.LoadInt64(&c.t))
+}
+
+func (c *fakeNow) set(t time.Time) {
+	atomic.StoreInt64(&c.t, t.UnixNano())
+}
+
+func withNow(now func() time.Time) WriterOption {
+	return func(o *writerOptions) {
+		o.now = now
+	}
+}
+
+func listDir(t *testing.T, dir string) []string {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, e := range entries {
+		names = append(names, e.Name())
+	}
+	sort.Strings(names)
+	return names
+}
+
+func TestFileWriterHourlyRotation(t *testing.T) {
+	dir := t.TempDir()
+	clock := new(fakeNow)
+	clock.set(time.Date(2021, 3, 4, 10, 30, 0, 0, time.Local))
+	f, err := NewFileWriter(dir, "server.log", 0, 0, WithRotate(RotateHourly), WithCompress(true), withNow(clock.now))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = f.LogWrite([]byte("first\n"))
+	_ = f.Flush(context.Background()) // 写协程读取时间前不能修改
+	clock.set(time.Date(2021, 3, 4, 11, 5, 0, 0, time.Local))
+	_ = f.LogWrite([]byte("second\n"))
+	f.Close()
+
+	want := []string{"server.20210304-10.0.log.gz", "server.20210304-11.0.log", "server.log"}
+	got := listDir(t, dir)
+	if len(got) != len(want) {
+		t.Fatalf("files %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("files %v, want %v", got, want)
+		}
+	}
+	if target, err := os.Readlink(filepath.Join(dir, "server.log")); err != nil || target != want[1] {
+		t.Fatalf("server.log -> %q, %v", target, err)
+	}
+	zf, err := os.Open(filepath.Join(dir, want[0]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer zf.Close()
+	zr, err := gzip.NewReader(zf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b, _ := io.ReadAll(zr); string(b) != "first\n" {
+		t.Fatalf("compressed content %q", b)
+	}
+}
+
+func TestFileWriterSizeRotationAndRetention(t *testing.T) {
+	dir := t.TempDir()
+	clock := new(fakeNow)
+	clock.set(time.Date(2021, 3, 4, 10, 30, 0, 0, time.Local))
+	// 超过保存时间的旧文件启动时删除
+	old := filepath.Join(dir, "server.20210301-00.0.log")
+	if err := os.WriteFile(old, []byte("old\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	expired := time.Now().Add(-2 * time.Hour)
+	_ = os.Chtimes(old, expired, expired)
+
+	f, err := NewFileWriter(dir, "server.log", 10, time.Hour, WithMaxBackups(2), withNow(clock.now))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 5; i++ {
+		_ = f.LogWrite([]byte("0123456789\n"))
+	}
+	f.Close()
+
+	want := []string{"server.20210304-10.3.log", "server.20210304-10.4.log", "server.20210304-10.5.log", "server.log"}
+	got := listDir(t, dir)
+	if len(got) != len(want) {
+		t.Fatalf("files %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("files %v, want %v", got, want)
+		}
+	}
+
+	// 重启后序号继续递增,不覆盖已有文件
+	f, err = NewFileWriter(dir, "server.log", 10, time.Hour, withNow(clock.now))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = f.LogWrite([]byte("0123456789\n"))
+	f.Close()
+	if target, _ := os.Readlink(filepath.Join(dir, "server.log")); target != "server.20210304-10.6.log" {
+		t.Fatalf("server.log -> %q after restart", target)
+	}
+}
+
+// 后台压缩跟不上时写协程不阻塞,关闭前压缩完所有旧文件
+func TestFileWriterRotateBurst(t *testing.T) {
+	dir := t.TempDir()
+	f, err := NewFileWriter(dir, "server.log", 10, 0, WithCompress(true))
+	if err != nil {
+		t.Fatal(err)
+	}
+	const n = 64
+	for i := 0; i < n; i++ {
+		_ = f.LogWrite([]byte("0123456789\n"))
+	}
+	f.Close()
+	gz := 0
+	for _, name := range listDir(t, dir) {
+		if filepath.Ext(name) == ".gz" {
+			gz++
+		}
+	}
+	if gz != n {
+		t.Fatalf("%d compressed files, want %d", gz, n)
+	}
+}
+
+func TestFileWriterFlushOnError(t *testing.T) {
+	dir := t.TempDir()
+	f, err := NewFileWriter(dir, "server.log", 0, 0, WithFlushInterval(0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	_ = f.LogWriteLevel(InfoLevel, []byte("info\n"))
+	_ = f.LogWriteLevel(ErrorLevel, []byte("error\n"))
+	// 未开启定时写出,Error写入时立即写出缓冲区
+	for deadline := time.Now().Add(time.Second); ; {
+		b, _ := os.ReadFile(filepath.Join(dir, "server.log"))
+		if string(b) == "info\nerror\n" {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("file content %q", b)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestDefaultLoggerRetention(t *testing.T) {
+	if DefaultMaxSaveTime < 24*time.Hour {
+		t.Fatalf("DefaultMaxSaveTime %v, want at least one day", DefaultMaxSaveTime)
+	}
+	var fw *FileWriter
+	for _, w := range Default().Writers[InfoLevel] {
+		if f, ok := w.(*FileWriter); ok {
+			fw = f
+		}
+	}
+	if fw == nil {
+		t.Fatal("default logger has no file writer")
+	}
+	if fw.maxSaveTime != DefaultMaxSaveTime {
+		t.Fatalf("default logger maxSaveTime %v, want %v", fw.maxSaveTime, DefaultMaxSaveTime)
+	}
+
+	// 一天前切分出的文件在默认配置下保留
+	dir := t.TempDir()
+	backup := filepath.Join(dir, "server.20210301-00.0.log")
+	if err := os.WriteFile(backup, []byte("old\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	mtime := time.Now().Add(-24 * time.Hour)
+	_ = os.Chtimes(backup, mtime, mtime)
+	f, err := NewFileWriter(dir, "server.log", 0, DefaultMaxSaveTime)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+	if _, err := os.Stat(backup); err != nil {
+		t.Fatalf("backup removed by default retention: %v", err)
+	}
+}
@@ -11,14 +11,17 @@ const (
 	FieldKeyTime           = "time"
 	FieldKeyFunc           = "func"
 	FieldKeyFile           = "file"
+	FieldKeyApp            = "app"
 )
 
+// Formatter 将Entry序列化为一行日志,可写入entry.Buffer以复用内存
 type Formatter interface {
 	Format(*Entry) ([]byte, error)
 }
 
 type fieldKey string
 
+// FieldMap 重命名内置字段,如FieldMap{FieldKeyMsg: "message"}
 type FieldMap map[fieldKey]string
 
 func (f FieldMap) resolve(key fieldKey) string {
@@ -48,18 +51,34 @@ func mergeField(data Fields, fieldMap FieldMap, reportCaller bool) {
 		delete(data, levelKey)
 	}
 
+	appKey := fieldMap.resolve(FieldKeyApp)
+	if a, ok := data[appKey]; ok {
+		data["fields."+appKey] = a
+		delete(data, appKey)
+	}
+
 	if reportCaller {
 		funcKey := fieldMap.resolve(FieldKeyFunc)
 		if l, ok := data[funcKey]; ok {
 			data["fields."+funcKey] = l
+			delete(data, funcKey)
 		}
 		fileKey := fieldMap.resolve(FieldKeyFile)
 		if l, ok := data[fileKey]; ok {
 			data["fields."+fileKey] = l
+			delete(data, fileKey)
 		}
 	}
 }
 
+// 日志来源的应用名,见WithName
+func appName(entry *Entry) string {
+	if entry.Logger == nil || entry.Logger.core().config == nil {
+		return ""
+	}
+	return entry.Logger.core().config.name
+}
+
 type NullFormatter struct {
 }
 
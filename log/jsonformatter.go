@@ -0,0 +1,82 @@
+package log
+
+import (
+	"bytes"
+	"fmt"
+	"runtime"
+
+	jsoniter "github.com/json-iterator/go"
+)
+
+var json = jsoniter.ConfigCompatibleWithStandardLibrary
+
+// JSONFormatter 每行输出一个json对象
+type JSONFormatter struct {
+	TimestampFormat  string //默认time.RFC3339
+	DisableTimestamp bool
+	DataKey          string //不为空时附加字段放在该key下
+	FieldMap         FieldMap
+	PrettyPrint      bool
+	// CallerPrettyfier 自定义func和file字段,返回空字符串时不输出该字段
+	CallerPrettyfier func(*runtime.Frame) (function string, file string)
+}
+
+func (f *JSONFormatter) Format(entry *Entry) ([]byte, error) {
+	data := make(Fields, len(entry.Data)+6)
+	for k, v := range entry.Data {
+		switch v := v.(type) {
+		case error:
+			//error一般没有导出字段,直接序列化为{}
+			data[k] = v.Error()
+		default:
+			data[k] = v
+		}
+	}
+	if f.DataKey != "" {
+		newData := make(Fields, 6)
+		newData[f.DataKey] = data
+		data = newData
+	}
+
+	mergeField(data, f.FieldMap, entry.HasCaller())
+
+	if !f.DisableTimestamp {
+		timestampFormat := f.TimestampFormat
+		if timestampFormat == "" {
+			timestampFormat = defaultTimestampFormat
+		}
+		data[f.FieldMap.resolve(FieldKeyTime)] = entry.Time.Format(timestampFormat)
+	}
+	data[f.FieldMap.resolve(FieldKeyMsg)] = entry.Message
+	data[f.FieldMap.resolve(FieldKeyLevel)] = entry.Level
+	if name := appName(entry); name != "" {
+		data[f.FieldMap.resolve(FieldKeyApp)] = name
+	}
+	if entry.HasCaller() {
+		funcVal := entry.Caller.Function
+		fileVal := fmt.Sprintf("%s:%d", entry.Caller.File, entry.Caller.Line)
+		if f.CallerPrettyfier != nil {
+			funcVal, fileVal = f.CallerPrettyfier(entry.Caller)
+		}
+		if funcVal != "" {
+			data[f.FieldMap.resolve(FieldKeyFunc)] = funcVal
+		}
+		if fileVal != "" {
+			data[f.FieldMap.resolve(FieldKeyFile)] = fileVal
+		}
+	}
+
+	b := entry.Buffer
+	if b == nil {
+		b = &bytes.Buffer{}
+	}
+	encoder := json.NewEncoder(b)
+	encoder.SetEscapeHTML(false)
+	if f.PrettyPrint {
+		encoder.SetIndent("", "  ")
+	}
+	if err := encoder.Encode(data); err != nil {
+		return nil, fmt.Errorf("failed to marshal fields to JSON, %v", err)
+	}
+	return b.Bytes(), nil
+}
@@ -1,11 +1,50 @@
 package log
 
-import "time"
+import (
+	"context"
+	"time"
+)
+
+// DefaultMaxSaveTime 默认Logger旧日志文件的保存时间
+const DefaultMaxSaveTime = 7 * 24 * time.Hour
 
 var defaultLogger *Logger
 
 func init() {
-	defaultLogger, _ = NewLogger(WithLogPath("./Logs"), WithMaxSize(100*1024*1024), WithMaxSaveTime(30*time.Second))
+	// 磁盘阻塞时优先丢弃低级别日志,避免网络读协程被阻塞
+	defaultLogger, _ = NewLogger(WithLogPath("./Logs"), WithMaxSize(100*1024*1024), WithMaxSaveTime(DefaultMaxSaveTime),
+		WithWriterOptions(WithOverflow(OverflowDropLowest)))
+}
+
+// Default 返回包级函数使用的默认Logger
+func Default() *Logger {
+	return defaultLogger
+}
+
+// Module 默认Logger的模块Logger
+func Module(name string) *Logger {
+	return defaultLogger.Module(name)
+}
+
+// SetModuleLevels 设置默认Logger的模块级别,格式见Logger.SetModuleLevels
+func SetModuleLevels(spec string) error {
+	return defaultLogger.SetModuleLevels(spec)
+}
+
+func WithField(key string, value interface{}) *Entry {
+	return defaultLogger.WithField(key, value)
+}
+
+func WithFields(fields Fields) *Entry {
+	return defaultLogger.WithFields(fields)
+}
+
+func WithError(err error) *Entry {
+	return defaultLogger.WithError(err)
+}
+
+func AddHook(hook Hook) {
+	defaultLogger.AddHook(hook)
 }
 
 func Panic(args ...interface{}) {
@@ -55,3 +94,7 @@ func Debug(args ...interface{}) {
 func Debugf(template string, args ...interface{}) {
 	defaultLogger.Debugf(template, args...)
 }
+
+func Flush(ctx context.Context) error {
+	return defaultLogger.Flush(ctx)
+}
@@ -1,19 +1,30 @@
 package log
 
 import (
+	"context"
 	"fmt"
 	"os"
-	"runtime"
 	"sync"
 	"sync/atomic"
 	"time"
 )
 
+const exitFlushTimeout = 10 * time.Second
+
 type Logger struct {
-	config  *LogConfig
-	Writers LevelWriters
-	closed  int32
-	mux     sync.Mutex
+	config       *LogConfig
+	Writers      LevelWriters
+	Hooks        LevelHooks
+	Formatter    Formatter //默认TextFormatter
+	ReportCaller bool      //是否记录调用位置,见WithDisableReportCaller
+	ExitFunc     func(int) //Fatal写出日志后调用,默认os.Exit,测试中可替换
+	closed       int32
+	level        int32 //Level 运行时可修改
+	mux          sync.Mutex
+	root         *Logger            //模块Logger所属的根Logger
+	module       string             //模块名
+	modules      map[string]*Logger //根Logger创建的模块Logger
+	sampler      *sampler           //见WithSampling
 }
 
 func NewLogger(opts ...Option) (*Logger, error) {
@@ -22,18 +33,35 @@ func NewLogger(opts ...Option) (*Logger, error) {
 	}
 	opt.LoadAllConfig(opts)
 	logger := &Logger{
-		config:  opt,
-		Writers: make(LevelWriters),
+		config:       opt,
+		Writers:      make(LevelWriters),
+		Hooks:        make(LevelHooks),
+		Formatter:    &TextFormatter{TimestampFormat: opt.timeFormat},
+		ReportCaller: !opt.DisableReportCaller,
+		ExitFunc:     os.Exit,
+		level:        int32(opt.logLevel),
+	}
+	if opt.formatter != nil {
+		logger.Formatter = opt.formatter
+	}
+	if opt.exitFunc != nil {
+		logger.ExitFunc = opt.exitFunc
+	}
+	if err := logger.SetModuleLevels(opt.moduleLevels); err != nil {
+		return nil, err
+	}
+	if len(opt.sampling) > 0 {
+		logger.sampler = newSampler(logger, opt.sampling)
 	}
 	if opt.stdout {
-		logger.AddWriter(NewStdWriter())
+		logger.AddWriter(NewStdWriter(opt.writerOpts...))
 	}
 	logFileName := "temp.log"
 	if opt.logFileName != "" {
 		logFileName = opt.logFileName
 	}
 	if opt.logPath != "" {
-		f, err := NewFileWriter(opt.logPath, logFileName, opt.maxSize, opt.maxSaveTime)
+		f, err := NewFileWriter(opt.logPath, logFileName, opt.maxSize, opt.maxSaveTime, opt.writerOpts...)
 		if err != nil {
 			return nil, err
 		}
@@ -44,20 +72,79 @@ func NewLogger(opts ...Option) (*Logger, error) {
 }
 
 func (l *Logger) AddWriter(logWriter LogWriter) {
+	l = l.core()
+	//内置writer的丢弃报告使用Logger的Formatter
+	if w, ok := logWriter.(interface{ attach(*Logger) }); ok {
+		w.attach(l)
+	}
+	l.mux.Lock()
 	l.Writers.Add(logWriter)
+	l.mux.Unlock()
+}
+
+func (l *Logger) AddHook(hook Hook) {
+	l = l.core()
+	l.mux.Lock()
+	l.Hooks.Add(hook)
+	l.mux.Unlock()
+}
+
+func (l *Logger) SetFormatter(formatter Formatter) {
+	l = l.core()
+	l.mux.Lock()
+	l.Formatter = formatter
+	l.mux.Unlock()
+}
+
+func (l *Logger) WithField(key string, value interface{}) *Entry {
+	return NewEntry(l).WithField(key, value)
+}
+
+func (l *Logger) WithFields(fields Fields) *Entry {
+	return NewEntry(l).WithFields(fields)
+}
+
+// WithError 以"error"为key附加错误
+func (l *Logger) WithError(err error) *Entry {
+	return NewEntry(l).WithField(ErrorKey, err)
+}
+
+func (l *Logger) WithTime(t time.Time) *Entry {
+	return NewEntry(l).WithTime(t)
 }
 
+// Flush 等待所有writer写出已缓存的日志
+func (l *Logger) Flush(ctx context.Context) error {
+	l = l.core()
+	l.mux.Lock()
+	writers := l.Writers.unique()
+	l.mux.Unlock()
+	for _, w := range writers {
+		if f, ok := w.(Flusher); ok {
+			if err := f.Flush(ctx); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Close 关闭所有writer,返回前已缓存的日志全部写出
 func (l *Logger) Close() {
-	if atomic.LoadInt32(&l.closed) == 1 {
+	l = l.core()
+	if !atomic.CompareAndSwapInt32(&l.closed, 0, 1) {
 		return
 	}
-	atomic.StoreInt32(&l.closed, 1)
-	for _, writers := range l.Writers {
-		for _, w := range writers {
-			w.Close()
-		}
+	if l.sampler != nil {
+		l.sampler.stop()
 	}
+	l.mux.Lock()
+	writers := l.Writers.unique()
 	l.Writers = nil
+	l.mux.Unlock()
+	for _, w := range writers {
+		w.Close()
+	}
 }
 
 func Copy(src []byte) (b []byte) {
@@ -71,39 +158,11 @@ func (l *Logger) log(level Level, message string) {
 	if !l.canOutput(level) {
 		return
 	}
-	var (
-		now         = time.Now()
-		appName     = l.config.name
-		levelPrefix = level.String()
-		timeDesc    = now.Format(SlashWithMillFormat)
-	)
-
-	b := bufferPool.Get()
-	if appName != "" {
-		b.WriteString("[")
-		b.WriteString(appName)
-		b.WriteString("] ")
-	}
-	b.WriteString(levelPrefix)
-	b.WriteString("[")
-	b.WriteString(timeDesc)
-	b.WriteString("] ")
-	if !l.config.DisableReportCaller {
-		_, fileName, line, ok := runtime.Caller(2)
-		if ok {
-			b.WriteString("[")
-			b.WriteString(fmt.Sprintf("%s:%d", fileName, line))
-			b.WriteString("] ")
-		}
-	}
-	b.WriteString(message)
-	b.WriteString("\n")
-	logStr := Copy(b.Bytes())
-	bufferPool.Put(b)
-	l.fireWrite(level, logStr)
+	NewEntry(l).log(level, message)
 }
 
 func (l *Logger) fireWrite(level Level, b []byte) {
+	l = l.core()
 	var tmpLevelWriters LevelWriters
 	l.mux.Lock()
 	tmpLevelWriters = make(LevelWriters, len(l.Writers))
@@ -117,26 +176,58 @@ func (l *Logger) fireWrite(level Level, b []byte) {
 	}
 }
 
+// exit 同步写出所有writer后调用ExitFunc
 func (l *Logger) exit() {
-	l.Close()
+	core := l.core()
+	core.sync()
+	if core.ExitFunc != nil {
+		core.ExitFunc(1)
+		return
+	}
 	os.Exit(1)
 }
 
+// sync Panic和Fatal日志写出后等待writer写完,磁盘阻塞时最多等待exitFlushTimeout
+func (l *Logger) sync() {
+	ctx, cancel := context.WithTimeout(context.Background(), exitFlushTimeout)
+	defer cancel()
+	if err := l.Flush(ctx); err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to flush log writers: %v\n", err)
+	}
+}
+
+func (l *Logger) IsLevelEnabled(level Level) bool {
+	return l.canOutput(level)
+}
+
 func (l *Logger) canOutput(level Level) bool {
-	if atomic.LoadInt32(&l.closed) == 1 {
+	if atomic.LoadInt32(&l.core().closed) == 1 {
 		return false
 	}
 	if !level.valid() {
 		return false
 	}
-	if l.config.logLevel < level {
+	if l.GetLevel() < level {
 		return false
 	}
 	return true
 }
 
+// SetLevel 运行时修改日志级别,模块Logger设置后不再跟随根Logger
+func (l *Logger) SetLevel(level Level) {
+	atomic.StoreInt32(&l.level, int32(level))
+}
+
+func (l *Logger) GetLevel() Level {
+	v := atomic.LoadInt32(&l.level)
+	if v == levelInherit && l.root != nil {
+		return l.root.GetLevel()
+	}
+	return Level(v)
+}
+
 func (l *Logger) Panic(args ...interface{}) {
-	l.log(PanicLevel, fmt.Sprint(args...))
+	NewEntry(l).panic(fmt.Sprint(args...))
 }
 
 func (l *Logger) Fatal(args ...interface{}) {
@@ -165,7 +256,7 @@ func (l *Logger) Warn(args ...interface{}) {
 }
 
 func (l *Logger) Panicln(args ...interface{}) {
-	l.log(PanicLevel, fmt.Sprintln(args...))
+	NewEntry(l).panic(fmt.Sprintln(args...))
 }
 
 func (l *Logger) FatalLn(args ...interface{}) {
@@ -194,7 +285,7 @@ func (l *Logger) Warnln(args ...interface{}) {
 }
 
 func (l *Logger) Panicf(format string, args ...interface{}) {
-	l.log(PanicLevel, fmt.Sprintf(format, args...))
+	NewEntry(l).panic(fmt.Sprintf(format, args...))
 }
 
 func (l *Logger) Fatalf(format string, args ...interface{}) {
@@ -1,6 +1,8 @@
 package log
 
 import (
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 )
@@ -26,3 +28,37 @@ func TestNewLogger(t *testing.T) {
 	time.Sleep(60 * time.Second)
 	l.Close()
 }
+
+// 与TestNewLogger相同的场景,10万个协程同时写文件
+func benchmarkConcurrentLog(b *testing.B, opts ...WriterOption) {
+	const goroutines = 100000
+	l, _ := NewLogger(WithDisableReportCaller(true))
+	f, err := NewFileWriter(b.TempDir(), "server.log", 100*1024*1024, 0, opts...)
+	if err != nil {
+		b.Fatal(err)
+	}
+	l.AddWriter(f)
+	//所有协程共享计数,总共写b.N行
+	var n int64
+	var wg sync.WaitGroup
+	wg.Add(goroutines)
+	b.ResetTimer()
+	for i := 0; i < goroutines; i++ {
+		go func(i int) {
+			defer wg.Done()
+			for atomic.AddInt64(&n, 1) <= int64(b.N) {
+				l.Info("hello world print ", i)
+			}
+		}(i)
+	}
+	wg.Wait()
+	l.Close()
+}
+
+func BenchmarkConcurrentLogBuffered(b *testing.B) {
+	benchmarkConcurrentLog(b)
+}
+
+func BenchmarkConcurrentLogUnbuffered(b *testing.B) {
+	benchmarkConcurrentLog(b, WithFlushSize(0))
+}
@@ -0,0 +1,79 @@
+package log
+
+import (
+	"sync"
+)
+
+// MemoryWriter 同步保存最近N行日志,用于admin接口和测试
+type MemoryWriter struct {
+	mux    sync.Mutex
+	lines  []string
+	next   int // 下一行写入的位置
+	full   bool
+	levels []Level
+}
+
+func NewMemoryWriter(n int) *MemoryWriter {
+	if n <= 0 {
+		n = 1
+	}
+	return &MemoryWriter{
+		lines:  make([]string, n),
+		levels: AllLevels,
+	}
+}
+
+func (m *MemoryWriter) SetLevels(levels []Level) {
+	m.levels = levels
+}
+
+func (m *MemoryWriter) Levels() []Level {
+	return m.levels
+}
+
+func (m *MemoryWriter) LogWrite(b []byte) error {
+	m.mux.Lock()
+	m.lines[m.next] = string(b)
+	m.next++
+	if m.next == len(m.lines) {
+		m.next = 0
+		m.full = true
+	}
+	m.mux.Unlock()
+	return nil
+}
+
+// Lines 保存的所有行,从旧到新
+func (m *MemoryWriter) Lines() []string {
+	return m.Tail(len(m.lines))
+}
+
+// Tail 最新的n行,从旧到新
+func (m *MemoryWriter) Tail(n int) []string {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+	count := m.next
+	if m.full {
+		count = len(m.lines)
+	}
+	if n <= 0 || n > count {
+		n = count
+	}
+	tail := make([]string, 0, n)
+	for i := m.next - n; i < m.next; i++ {
+		tail = append(tail, m.lines[(i+len(m.lines))%len(m.lines)])
+	}
+	return tail
+}
+
+func (m *MemoryWriter) Reset() {
+	m.mux.Lock()
+	for i := range m.lines {
+		m.lines[i] = ""
+	}
+	m.next = 0
+	m.full = false
+	m.mux.Unlock()
+}
+
+func (m *MemoryWriter) Close() {}
@@ -0,0 +1,114 @@
+package log
+
+import (
+	"fmt"
+	"strings"
+	"sync/atomic"
+)
+
+// 模块Logger未单独设置级别时使用根Logger的级别
+const levelInherit = -1
+
+// Module 返回名为name的子Logger,共享根Logger的writer、hook和formatter,
+// 每行日志附加module字段,可通过SetLevel或SetModuleLevels单独设置级别
+func (l *Logger) Module(name string) *Logger {
+	root := l.core()
+	root.mux.Lock()
+	defer root.mux.Unlock()
+	if m, ok := root.modules[name]; ok {
+		return m
+	}
+	if root.modules == nil {
+		root.modules = make(map[string]*Logger)
+	}
+	m := &Logger{
+		root:   root,
+		module: name,
+		level:  levelInherit,
+	}
+	root.modules[name] = m
+	return m
+}
+
+// Name 模块名,根Logger为空
+func (l *Logger) Name() string {
+	return l.module
+}
+
+// ResetLevel 模块Logger恢复使用根Logger的级别
+func (l *Logger) ResetLevel() {
+	if l.root != nil {
+		atomic.StoreInt32(&l.level, levelInherit)
+	}
+}
+
+// ModuleLevels 单独设置了级别的模块
+func (l *Logger) ModuleLevels() map[string]Level {
+	root := l.core()
+	root.mux.Lock()
+	defer root.mux.Unlock()
+	levels := make(map[string]Level)
+	for name, m := range root.modules {
+		if v := atomic.LoadInt32(&m.level); v != levelInherit {
+			levels[name] = Level(v)
+		}
+	}
+	return levels
+}
+
+// SetModuleLevels 按"network=debug,timer=warn"设置模块级别,
+// 不带模块名的项如"info"或"*=info"设置根Logger,"network=default"恢复使用根Logger的级别
+func (l *Logger) SetModuleLevels(spec string) error {
+	levels, err := ParseLevelSpec(spec)
+	if err != nil {
+		return err
+	}
+	root := l.core()
+	for name, level := range levels {
+		switch {
+		case name == "":
+			root.SetLevel(level)
+		case level == levelInherit:
+			root.Module(name).ResetLevel()
+		default:
+			root.Module(name).SetLevel(level)
+		}
+	}
+	return nil
+}
+
+// ParseLevelSpec 解析SetModuleLevels的配置,根Logger的模块名为空,恢复默认的级别为-1
+func ParseLevelSpec(spec string) (map[string]Level, error) {
+	levels := make(map[string]Level)
+	for _, item := range strings.Split(spec, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		name, value := "", item
+		if i := strings.IndexByte(item, '='); i >= 0 {
+			name, value = strings.TrimSpace(item[:i]), item[i+1:]
+		}
+		if name == "*" {
+			name = ""
+		}
+		if name != "" && strings.TrimSpace(value) == "default" {
+			levels[name] = levelInherit
+			continue
+		}
+		level, err := ParseLevel(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid level spec %q: %v", item, err)
+		}
+		levels[name] = level
+	}
+	return levels, nil
+}
+
+// core 共享状态所在的根Logger
+func (l *Logger) core() *Logger {
+	if l.root != nil {
+		return l.root
+	}
+	return l
+}
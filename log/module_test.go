@@ -0,0 +1,52 @@
+package log
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestModuleLevels(t *testing.T) {
+	l, w := newCaptureLogger(t, WithDisableReportCaller(true), WithModuleLevels("network=debug"))
+	network := l.Module("network")
+	timer := l.Module("timer")
+	if l.Module("network") != network {
+		t.Fatal("Module returned a new logger for the same name")
+	}
+	network.Debug("network debug")
+	timer.Debug("timer debug")
+	timer.Info("timer info")
+
+	// 未单独设置的模块跟随根Logger
+	l.SetLevel(WarnLevel)
+	timer.Info("timer info filtered")
+	if err := l.SetModuleLevels("warn,network=error,timer=debug"); err != nil {
+		t.Fatal(err)
+	}
+	network.Warn("network warn filtered")
+	timer.Debug("timer debug 2")
+	if err := l.SetModuleLevels("timer=default"); err != nil {
+		t.Fatal(err)
+	}
+	timer.Info("timer info filtered 2")
+	if err := l.SetModuleLevels("network=verbose"); err == nil {
+		t.Fatal("expected error for invalid level")
+	}
+
+	lines := w.Lines()
+	want := []string{
+		"network debug module=network",
+		"timer info module=timer",
+		"timer debug 2 module=timer",
+	}
+	if len(lines) != len(want) {
+		t.Fatalf("got %q", lines)
+	}
+	for i := range want {
+		if !strings.HasSuffix(lines[i], want[i]+"\n") {
+			t.Fatalf("line %d = %q, want suffix %q", i, lines[i], want[i])
+		}
+	}
+	if levels := l.ModuleLevels(); len(levels) != 1 || levels["network"] != ErrorLevel {
+		t.Fatalf("ModuleLevels = %v", levels)
+	}
+}
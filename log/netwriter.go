@@ -0,0 +1,178 @@
+package log
+
+import (
+	"fmt"
+	"net"
+	"time"
+)
+
+const (
+	netDialTimeout  = 3 * time.Second
+	netWriteTimeout = 3 * time.Second
+	minRedialDelay  = 100 * time.Millisecond
+	maxRedialDelay  = 10 * time.Second
+)
+
+// redialer 写失败后断开,按指数退避重新连接,
+// 未送达的行保留在pending中,重连后按顺序重新发送,超出limit后丢弃新行
+type redialer struct {
+	network  string
+	addr     string
+	conn     net.Conn
+	delay    time.Duration
+	nextDial time.Time
+	pending  [][]byte
+	limit    int
+}
+
+// send 先发送之前未送达的行,返回false表示pending已满b被丢弃
+func (r *redialer) send(b []byte) bool {
+	if len(r.pending) >= r.limit {
+		r.retry()
+		if len(r.pending) >= r.limit {
+			return false
+		}
+	}
+	r.pending = append(r.pending, b)
+	r.retry()
+	return true
+}
+
+// retry 按顺序发送未送达的行,失败时等待下次send或flush
+func (r *redialer) retry() {
+	for len(r.pending) > 0 {
+		if err := r.write(r.pending[0]); err != nil {
+			return
+		}
+		r.pending[0] = nil
+		r.pending = r.pending[1:]
+	}
+	r.pending = nil
+}
+
+func (r *redialer) write(b []byte) error {
+	if r.conn == nil {
+		if err := r.dial(); err != nil {
+			return err
+		}
+	}
+	_ = r.conn.SetWriteDeadline(time.Now().Add(netWriteTimeout))
+	if _, err := r.conn.Write(b); err != nil {
+		_ = r.conn.Close()
+		r.conn = nil
+		// 连接可能已被对端关闭,立即重连一次
+		if err = r.dial(); err != nil {
+			return err
+		}
+		_ = r.conn.SetWriteDeadline(time.Now().Add(netWriteTimeout))
+		if _, err = r.conn.Write(b); err != nil {
+			r.fail()
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *redialer) dial() error {
+	if time.Now().Before(r.nextDial) {
+		return fmt.Errorf("%s %s: waiting to redial", r.network, r.addr)
+	}
+	conn, err := net.DialTimeout(r.network, r.addr, netDialTimeout)
+	if err != nil {
+		r.fail()
+		return err
+	}
+	r.conn = conn
+	r.delay = 0
+	return nil
+}
+
+func (r *redialer) fail() {
+	if r.conn != nil {
+		_ = r.conn.Close()
+		r.conn = nil
+	}
+	if r.delay == 0 {
+		r.delay = minRedialDelay
+	} else if r.delay *= 2; r.delay > maxRedialDelay {
+		r.delay = maxRedialDelay
+	}
+	r.nextDial = time.Now().Add(r.delay)
+}
+
+// close 断开连接,返回未能送达的行数
+func (r *redialer) close() int {
+	n := len(r.pending)
+	r.pending = nil
+	if r.conn != nil {
+		_ = r.conn.Close()
+		r.conn = nil
+	}
+	return n
+}
+
+// NetWriter 将日志行发送到TCP等流式连接的收集端,断线自动重连,
+// 写缓存默认满时丢弃新行,不阻塞写日志的协程
+type NetWriter struct {
+	*lineQueue
+	conn   *redialer
+	levels []Level
+}
+
+func NewNetWriter(network, addr string, opts ...WriterOption) (*NetWriter, error) {
+	switch network {
+	case "tcp", "tcp4", "tcp6", "unix":
+	default:
+		return nil, fmt.Errorf("log: unsupported network %q for NetWriter", network)
+	}
+	opts = append([]WriterOption{WithOverflow(OverflowDropNewest)}, opts...)
+	q := newLineQueue("net", opts)
+	w := &NetWriter{
+		lineQueue: q,
+		conn:      &redialer{network: network, addr: addr, limit: q.opt.bufferSize},
+		levels:    AllLevels,
+	}
+	w.Start()
+	return w, nil
+}
+
+func (w *NetWriter) Start() {
+	w.start(w)
+}
+
+func (w *NetWriter) writeLine(_ Level, b []byte) {
+	if !w.conn.send(b) {
+		w.drop()
+	}
+}
+
+// flush 定时重发断线期间保留的行
+func (w *NetWriter) flush() {
+	w.conn.retry()
+}
+
+func (w *NetWriter) SetLevels(levels []Level) {
+	w.levels = levels
+}
+
+func (w *NetWriter) Levels() []Level {
+	return w.levels
+}
+
+func (w *NetWriter) LogWrite(b []byte) error {
+	w.push(InfoLevel, b)
+	return nil
+}
+
+func (w *NetWriter) LogWriteLevel(level Level, b []byte) error {
+	w.push(level, b)
+	return nil
+}
+
+// Close 发送完缓存中的日志后断开连接,仍未送达的计入丢弃
+func (w *NetWriter) Close() {
+	w.close()
+	for n := w.conn.close(); n > 0; n-- {
+		w.drop()
+	}
+}
@@ -15,6 +15,12 @@ type LogConfig struct {
 	logPath             string        // 日志存储路径
 	logFileName         string        // 日志文件名
 	DisableReportCaller bool
+	formatter           Formatter
+	writerOpts          []WriterOption
+	moduleLevels        string
+	exitFunc            func(int)
+	timeFormat          string
+	sampling            map[Level]Sampling
 }
 
 func (l *LogConfig) LoadAllConfig(op []Option) {
@@ -35,6 +41,7 @@ func WithLogLevel(level Level) Option {
 	}
 }
 
+// WithMaxSaveTime 按修改时间删除旧日志文件,<=0不按时间删除
 func WithMaxSaveTime(t time.Duration) Option {
 	return func(l *LogConfig) {
 		l.maxSaveTime = t
@@ -70,3 +77,53 @@ func WithDisableReportCaller(b bool) Option {
 		l.DisableReportCaller = b
 	}
 }
+
+// WithFormatter 默认TextFormatter,可选JSONFormatter
+func WithFormatter(formatter Formatter) Option {
+	return func(l *LogConfig) {
+		l.formatter = formatter
+	}
+}
+
+// WithWriterOptions 内置FileWriter和StdWriter的写缓存配置
+func WithWriterOptions(opts ...WriterOption) Option {
+	return func(l *LogConfig) {
+		l.writerOpts = append(l.writerOpts, opts...)
+	}
+}
+
+// WithModuleLevels 模块级别配置,格式见Logger.SetModuleLevels
+func WithModuleLevels(spec string) Option {
+	return func(l *LogConfig) {
+		l.moduleLevels = spec
+	}
+}
+
+// WithExitFunc Fatal写出日志后的退出函数,默认os.Exit
+func WithExitFunc(f func(int)) Option {
+	return func(l *LogConfig) {
+		l.exitFunc = f
+	}
+}
+
+// WithTimeFormat 默认TextFormatter的时间格式,如SlashFormat、DashWithMillFormat
+func WithTimeFormat(layout string) Option {
+	return func(l *LogConfig) {
+		l.timeFormat = layout
+	}
+}
+
+// WithSampling 对指定级别的日志采样,不指定级别时对Error及以下所有级别生效,Panic和Fatal不采样
+func WithSampling(rule Sampling, levels ...Level) Option {
+	return func(l *LogConfig) {
+		if len(levels) == 0 {
+			levels = []Level{ErrorLevel, WarnLevel, InfoLevel, DebugLevel}
+		}
+		if l.sampling == nil {
+			l.sampling = make(map[Level]Sampling)
+		}
+		for _, level := range levels {
+			l.sampling[level] = rule
+		}
+	}
+}
@@ -0,0 +1,60 @@
+package log
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func newFileLogger(t *testing.T, opts ...Option) (*Logger, func() string) {
+	dir := t.TempDir()
+	opts = append(opts, WithLogPath(dir), WithLogFileName("exit.log"), WithDisableReportCaller(true))
+	l, err := NewLogger(opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(l.Close)
+	return l, func() string {
+		b, _ := os.ReadFile(filepath.Join(dir, "exit.log"))
+		return string(b)
+	}
+}
+
+func TestPanicWritesThenPanics(t *testing.T) {
+	l, content := newFileLogger(t)
+	func() {
+		defer func() {
+			if r := recover(); r != "boom 1" {
+				t.Fatalf("recovered %v, want boom 1", r)
+			}
+		}()
+		l.WithField("session", 7).Panicf("boom %d", 1)
+		t.Fatal("Panicf returned")
+	}()
+	// panic前已同步写出
+	if c := content(); !strings.Contains(c, "[PANIC]") || !strings.Contains(c, "boom 1 session=7") {
+		t.Fatalf("file content %q", c)
+	}
+}
+
+func TestFatalFlushesBeforeExit(t *testing.T) {
+	code := -1
+	var atExit string
+	var content func() string
+	var l *Logger
+	l, content = newFileLogger(t, WithExitFunc(func(c int) {
+		code = c
+		atExit = content()
+	}))
+	for i := 0; i < 100; i++ {
+		l.Info("before fatal")
+	}
+	l.Fatal("fatal line")
+	if code != 1 {
+		t.Fatalf("exit code %d, want 1", code)
+	}
+	if strings.Count(atExit, "before fatal") != 100 || !strings.Contains(atExit, "[FATAL]") {
+		t.Fatalf("file content at exit %q", atExit)
+	}
+}
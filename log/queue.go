@@ -0,0 +1,313 @@
+package log
+
+import (
+	"context"
+	"fmt"
+	"jnet/metrics"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// OverflowPolicy 写缓存满时的处理方式
+type OverflowPolicy int32
+
+const (
+	OverflowBlock      OverflowPolicy = iota // 阻塞直到有空位,默认
+	OverflowDropNewest                       // 丢弃新写入的行
+	OverflowDropLowest                       // 按级别提前丢弃,Debug最先,Error及以上阻塞
+)
+
+const (
+	defaultBufferSize     = 1 << 10
+	defaultReportInterval = 10 * time.Second
+	defaultFlushSize      = 64 << 10
+	defaultFlushInterval  = time.Second
+	maxBatch              = 256 // 写协程每次最多连续取出的行数
+)
+
+// OverflowDropLowest下各级别可使用的缓存比例,Error及以上不丢弃
+var dropThreshold = [...]float64{
+	WarnLevel:  0.9,
+	InfoLevel:  0.75,
+	DebugLevel: 0.5,
+}
+
+// LevelLogWriter 需要按级别处理的LogWriter,LevelWriters.Fire优先调用
+type LevelLogWriter interface {
+	LogWriteLevel(level Level, b []byte) error
+}
+
+// Flusher 可将缓存中的日志同步写出的LogWriter
+type Flusher interface {
+	Flush(ctx context.Context) error
+}
+
+type writerOptions struct {
+	bufferSize     int
+	overflow       OverflowPolicy
+	reportInterval time.Duration
+	flushInterval  time.Duration
+	// 以下只对StdWriter生效
+	color  ColorMode
+	stderr bool
+	// 以下只对FileWriter生效
+	flushSize  int
+	rotate     RotatePeriod
+	compress   bool
+	maxBackups int
+	now        func() time.Time
+}
+
+type WriterOption func(o *writerOptions)
+
+// WithBufferSize 写缓存行数,默认1024
+func WithBufferSize(n int) WriterOption {
+	return func(o *writerOptions) {
+		o.bufferSize = n
+	}
+}
+
+// WithOverflow 写缓存满时的处理方式,默认OverflowBlock
+func WithOverflow(policy OverflowPolicy) WriterOption {
+	return func(o *writerOptions) {
+		o.overflow = policy
+	}
+}
+
+// WithDropReport 丢弃行数写入日志的间隔,默认10s,<=0不写入
+func WithDropReport(interval time.Duration) WriterOption {
+	return func(o *writerOptions) {
+		o.reportInterval = interval
+	}
+}
+
+// WithFlushInterval 定时写出缓冲区的间隔,默认1s,<=0不定时写出
+func WithFlushInterval(interval time.Duration) WriterOption {
+	return func(o *writerOptions) {
+		o.flushInterval = interval
+	}
+}
+
+func loadWriterOptions(opts []WriterOption) *writerOptions {
+	o := &writerOptions{
+		bufferSize:     defaultBufferSize,
+		reportInterval: defaultReportInterval,
+		flushInterval:  defaultFlushInterval,
+		flushSize:      defaultFlushSize,
+		stderr:         true,
+		now:            time.Now,
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+	if o.bufferSize <= 0 {
+		o.bufferSize = defaultBufferSize
+	}
+	return o
+}
+
+type logLine struct {
+	level   Level
+	b       []byte
+	flushed chan struct{} // 非nil时为Flush标记
+}
+
+// lineOutput 写协程实际的输出
+type lineOutput interface {
+	writeLine(level Level, b []byte)
+	flush()
+}
+
+// lineQueue 单个写协程消费的写缓存,FileWriter和StdWriter共用
+type lineQueue struct {
+	name    string // 指标中的writer标签
+	opt     *writerOptions
+	lines   chan logLine
+	dropped int64 // 上次报告后丢弃的行数
+	closed  int32
+	pushMux sync.RWMutex // close等待正在写入的行进入缓存后再通知写协程退出
+	logger  atomic.Value // *Logger,丢弃报告使用其Formatter
+	exit    chan struct{}
+	stopped chan struct{}
+	WgWrapper
+	sync.Once
+}
+
+func newLineQueue(name string, opts []WriterOption) *lineQueue {
+	opt := loadWriterOptions(opts)
+	return &lineQueue{
+		name:    name,
+		opt:     opt,
+		lines:   make(chan logLine, opt.bufferSize),
+		exit:    make(chan struct{}),
+		stopped: make(chan struct{}),
+	}
+}
+
+func (q *lineQueue) start(out lineOutput) {
+	q.Wrap(func() {
+		defer close(q.stopped)
+		var report, flush <-chan time.Time
+		if q.opt.reportInterval > 0 {
+			ticker := time.NewTicker(q.opt.reportInterval)
+			defer ticker.Stop()
+			report = ticker.C
+		}
+		if q.opt.flushInterval > 0 {
+			ticker := time.NewTicker(q.opt.flushInterval)
+			defer ticker.Stop()
+			flush = ticker.C
+		}
+		for {
+			select {
+			case line := <-q.lines:
+				q.handle(out, line)
+				q.batch(out)
+			case <-flush:
+				out.flush()
+			case <-report:
+				q.reportDropped(out)
+			case <-q.exit:
+				q.drain(out)
+				return
+			}
+		}
+	})
+}
+
+func (q *lineQueue) handle(out lineOutput, line logLine) {
+	if line.flushed != nil {
+		out.flush()
+		close(line.flushed)
+		return
+	}
+	out.writeLine(line.level, line.b)
+}
+
+// batch 连续取出已缓存的行,减少select的开销
+func (q *lineQueue) batch(out lineOutput) {
+	for i := 0; i < maxBatch; i++ {
+		select {
+		case line := <-q.lines:
+			q.handle(out, line)
+		default:
+			return
+		}
+	}
+}
+
+// 关闭时写完缓存中剩余的行
+func (q *lineQueue) drain(out lineOutput) {
+	for {
+		select {
+		case line := <-q.lines:
+			q.handle(out, line)
+		default:
+			q.reportDropped(out)
+			out.flush()
+			return
+		}
+	}
+}
+
+// attach 由Logger.AddWriter调用
+func (q *lineQueue) attach(l *Logger) {
+	q.logger.Store(l)
+}
+
+// reportDropped 丢弃报告按普通日志格式化,未加入Logger时使用TextFormatter
+func (q *lineQueue) reportDropped(out lineOutput) {
+	n := atomic.SwapInt64(&q.dropped, 0)
+	if n == 0 {
+		return
+	}
+	entry := &Entry{
+		Level:    WarnLevel,
+		Time:     time.Now(),
+		Message:  "log writer dropped lines",
+		Data:     Fields{"writer": q.name, "dropped": n},
+		noSample: true,
+	}
+	var formatter Formatter = &TextFormatter{}
+	if l, ok := q.logger.Load().(*Logger); ok {
+		entry.Logger = l
+		formatter = l.core().Formatter
+	}
+	b, err := formatter.Format(entry)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to format drop report, %v\n", err)
+		return
+	}
+	out.writeLine(WarnLevel, b)
+}
+
+func (q *lineQueue) drop() {
+	atomic.AddInt64(&q.dropped, 1)
+	metrics.AddCounter(metricDroppedLines, 1, "writer", q.name)
+}
+
+// push close之后写入的行计入丢弃
+func (q *lineQueue) push(level Level, b []byte) {
+	q.pushMux.RLock()
+	defer q.pushMux.RUnlock()
+	if atomic.LoadInt32(&q.closed) == 1 {
+		q.drop()
+		return
+	}
+	line := logLine{level: level, b: b}
+	switch q.opt.overflow {
+	case OverflowDropNewest:
+		q.tryPush(line)
+		return
+	case OverflowDropLowest:
+		if level > ErrorLevel && int(level) < len(dropThreshold) {
+			if float64(len(q.lines)) >= dropThreshold[level]*float64(cap(q.lines)) {
+				q.drop()
+				return
+			}
+			q.tryPush(line)
+			return
+		}
+	}
+	q.lines <- line
+}
+
+func (q *lineQueue) tryPush(line logLine) {
+	select {
+	case q.lines <- line:
+	default:
+		q.drop()
+	}
+}
+
+// Flush 等待调用前写入的行全部写出
+func (q *lineQueue) Flush(ctx context.Context) error {
+	flushed := make(chan struct{})
+	select {
+	case q.lines <- logLine{flushed: flushed}:
+	case <-q.stopped:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	select {
+	case <-flushed:
+	case <-q.stopped:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	return nil
+}
+
+// close 停止写协程,返回前缓存中的行已写出
+func (q *lineQueue) close() {
+	q.Do(func() {
+		q.pushMux.Lock()
+		atomic.StoreInt32(&q.closed, 1)
+		q.pushMux.Unlock()
+		close(q.exit)
+		q.Wait()
+	})
+}
@@ -0,0 +1,126 @@
+package log
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type recordOutput struct {
+	lines []string
+}
+
+func (r *recordOutput) writeLine(_ Level, b []byte) {
+	r.lines = append(r.lines, string(b))
+}
+
+func (r *recordOutput) flush() {}
+
+// 写协程未启动时写缓存不会被消费,可以确定性地填满
+func fillQueue(q *lineQueue, level Level, n int) {
+	for i := 0; i < n; i++ {
+		q.push(level, []byte(fmt.Sprintf("%s %d\n", level, i)))
+	}
+}
+
+func TestOverflowDropNewest(t *testing.T) {
+	q := newLineQueue("test", []WriterOption{WithBufferSize(4), WithOverflow(OverflowDropNewest), WithDropReport(0)})
+	fillQueue(q, ErrorLevel, 10)
+	out := new(recordOutput)
+	q.start(out)
+	q.close()
+	if len(out.lines) != 5 {
+		t.Fatalf("got %d lines, want 4 lines and a drop report: %q", len(out.lines), out.lines)
+	}
+	if !strings.HasSuffix(out.lines[4], "log writer dropped lines dropped=6 writer=test\n") {
+		t.Fatalf("unexpected drop report %q", out.lines[4])
+	}
+}
+
+func TestOverflowDropLowest(t *testing.T) {
+	q := newLineQueue("test", []WriterOption{WithBufferSize(4), WithOverflow(OverflowDropLowest), WithDropReport(0)})
+	fillQueue(q, DebugLevel, 4) // 缓存使用到50%后丢弃
+	fillQueue(q, InfoLevel, 2)  // 75%
+	fillQueue(q, WarnLevel, 1)
+	out := new(recordOutput)
+	q.start(out)
+	q.close()
+	want := []string{"[DEBUG] 0\n", "[DEBUG] 1\n", "[INFO] 0\n", "[WARN] 0\n"}
+	if len(out.lines) != len(want)+1 {
+		t.Fatalf("got %q", out.lines)
+	}
+	for i, line := range want {
+		if out.lines[i] != line {
+			t.Fatalf("line %d = %q, want %q", i, out.lines[i], line)
+		}
+	}
+	if !strings.HasSuffix(out.lines[4], "log writer dropped lines dropped=3 writer=test\n") {
+		t.Fatalf("unexpected drop report %q", out.lines[4])
+	}
+}
+
+func TestDropReportFormatter(t *testing.T) {
+	l, _ := NewLogger(WithFormatter(&JSONFormatter{}))
+	defer l.Close()
+	q := newLineQueue("test", []WriterOption{WithBufferSize(1), WithOverflow(OverflowDropNewest), WithDropReport(0)})
+	q.attach(l)
+	fillQueue(q, ErrorLevel, 3)
+	out := new(recordOutput)
+	q.start(out)
+	q.close()
+	if len(out.lines) != 2 {
+		t.Fatalf("got %q", out.lines)
+	}
+	var report map[string]interface{}
+	if err := json.Unmarshal([]byte(out.lines[1]), &report); err != nil {
+		t.Fatalf("drop report is not json: %q", out.lines[1])
+	}
+	if report["writer"] != "test" || report["dropped"] != float64(2) {
+		t.Fatalf("unexpected drop report %v", report)
+	}
+
+	// close之后写入的行计入丢弃
+	q.push(InfoLevel, []byte("late\n"))
+	if n := atomic.LoadInt64(&q.dropped); n != 1 {
+		t.Fatalf("%d lines dropped after close, want 1", n)
+	}
+}
+
+func TestFileWriterFlushAndClose(t *testing.T) {
+	dir := t.TempDir()
+	l, err := NewLogger(WithLogPath(dir), WithLogFileName("flush.log"), WithDisableReportCaller(true))
+	if err != nil {
+		t.Fatal(err)
+	}
+	countLines := func() int {
+		b, err := os.ReadFile(filepath.Join(dir, "flush.log"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return bytes.Count(b, []byte("\n"))
+	}
+	for i := 0; i < 500; i++ {
+		l.Info("line ", i)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := l.Flush(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if n := countLines(); n != 500 {
+		t.Fatalf("%d lines after Flush, want 500", n)
+	}
+	for i := 0; i < 500; i++ {
+		l.Info("line ", i)
+	}
+	l.Close()
+	if n := countLines(); n != 1000 {
+		t.Fatalf("%d lines after Close, want 1000", n)
+	}
+}
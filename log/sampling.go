@@ -0,0 +1,130 @@
+package log
+
+import (
+	"fmt"
+	"jnet/metrics"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// 被采样丢弃的日志行数
+const metricSampledLines = "jnet_log_sampled_total"
+
+// Sampling 相同级别、消息和调用位置的日志在每个周期内先输出First条,之后每Thereafter条输出1条
+type Sampling struct {
+	Interval   time.Duration //统计周期,默认1s
+	First      int
+	Thereafter int //<=0时超过First后全部丢弃
+}
+
+// sampler 按级别采样,每个周期结束时输出被丢弃的条数
+type sampler struct {
+	logger     *Logger
+	rules      [DebugLevel + 1]*Sampling
+	tick       time.Duration
+	mux        sync.Mutex
+	counts     [DebugLevel + 1]map[string]int
+	starts     [DebugLevel + 1]time.Time
+	suppressed [DebugLevel + 1]int
+	exit       chan struct{}
+	once       sync.Once
+	WgWrapper
+}
+
+func newSampler(logger *Logger, rules map[Level]Sampling) *sampler {
+	s := &sampler{
+		logger: logger,
+		exit:   make(chan struct{}),
+	}
+	now := time.Now()
+	for level, rule := range rules {
+		if !level.valid() || level <= FatalLevel {
+			continue
+		}
+		rule := rule
+		if rule.Interval <= 0 {
+			rule.Interval = time.Second
+		}
+		s.rules[level] = &rule
+		s.counts[level] = make(map[string]int)
+		s.starts[level] = now
+		if s.tick == 0 || rule.Interval < s.tick {
+			s.tick = rule.Interval
+		}
+	}
+	if s.tick == 0 {
+		return nil
+	}
+	s.Wrap(s.run)
+	return s
+}
+
+func (s *sampler) run() {
+	ticker := time.NewTicker(s.tick)
+	defer ticker.Stop()
+	for {
+		select {
+		case now := <-ticker.C:
+			s.report(now, false)
+		case <-s.exit:
+			s.report(time.Now(), true)
+			return
+		}
+	}
+}
+
+// allow 是否输出,entry的Caller需已获取
+func (s *sampler) allow(entry *Entry) bool {
+	rule := s.rules[entry.Level]
+	if rule == nil {
+		return true
+	}
+	key := entry.Message
+	if entry.Caller != nil {
+		key = entry.Caller.File + ":" + strconv.Itoa(entry.Caller.Line) + " " + key
+	}
+	s.mux.Lock()
+	n := s.counts[entry.Level][key] + 1
+	s.counts[entry.Level][key] = n
+	ok := n <= rule.First || (rule.Thereafter > 0 && (n-rule.First)%rule.Thereafter == 0)
+	if !ok {
+		s.suppressed[entry.Level]++
+	}
+	s.mux.Unlock()
+	if !ok {
+		metrics.AddCounter(metricSampledLines, 1, "level", levelName[entry.Level])
+	}
+	return ok
+}
+
+// report 结束已到期的周期,输出本周期丢弃的条数
+func (s *sampler) report(now time.Time, all bool) {
+	var suppressed [DebugLevel + 1]int
+	s.mux.Lock()
+	for level, rule := range s.rules {
+		if rule == nil || (!all && now.Sub(s.starts[level]) < rule.Interval) {
+			continue
+		}
+		suppressed[level] = s.suppressed[level]
+		s.suppressed[level] = 0
+		s.counts[level] = make(map[string]int)
+		s.starts[level] = now
+	}
+	s.mux.Unlock()
+	for level, n := range suppressed {
+		if n > 0 {
+			entry := NewEntry(s.logger)
+			entry.noSample = true
+			entry.WithField("suppressed", n).log(Level(level), fmt.Sprintf("suppressed %d messages", n))
+		}
+	}
+}
+
+// stop 输出剩余的丢弃条数后停止
+func (s *sampler) stop() {
+	s.once.Do(func() {
+		close(s.exit)
+		s.Wait()
+	})
+}
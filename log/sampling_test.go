@@ -0,0 +1,59 @@
+package log
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSampling(t *testing.T) {
+	l, w := newCaptureLogger(t, WithDisableReportCaller(true),
+		WithSampling(Sampling{Interval: time.Hour, First: 3, Thereafter: 10}, ErrorLevel))
+	for i := 0; i < 100; i++ {
+		l.Error("bad packet")
+	}
+	for i := 0; i < 5; i++ {
+		l.Info("not sampled")
+	}
+	l.Error("other")
+	l.Close()
+
+	count := make(map[string]int)
+	var summary string
+	for _, line := range w.Lines() {
+		switch {
+		case strings.Contains(line, "suppressed"):
+			summary = line
+		case strings.Contains(line, "bad packet"):
+			count["bad packet"]++
+		case strings.Contains(line, "not sampled"):
+			count["not sampled"]++
+		case strings.Contains(line, "other"):
+			count["other"]++
+		}
+	}
+	// 前3条,之后第13、23...93条
+	if count["bad packet"] != 12 || count["not sampled"] != 5 || count["other"] != 1 {
+		t.Fatalf("counts %v", count)
+	}
+	if !strings.Contains(summary, "[ERROR]") || !strings.HasSuffix(summary, "suppressed 88 messages suppressed=88\n") {
+		t.Fatalf("summary %q", summary)
+	}
+}
+
+func TestSamplingInterval(t *testing.T) {
+	l, w := newCaptureLogger(t, WithDisableReportCaller(true),
+		WithSampling(Sampling{Interval: 20 * time.Millisecond, First: 1}))
+	defer l.Close()
+	l.Warn("storm")
+	l.Warn("storm")
+	// 周期结束后输出汇总,并重新计数
+	for deadline := time.Now().Add(time.Second); len(w.Lines()) < 2 && time.Now().Before(deadline); {
+		time.Sleep(time.Millisecond)
+	}
+	l.Warn("storm")
+	lines := w.Lines()
+	if len(lines) != 3 || !strings.Contains(lines[1], "suppressed 1 messages") || !strings.Contains(lines[2], "storm") {
+		t.Fatalf("got %q", lines)
+	}
+}
@@ -1,48 +1,113 @@
 package log
 
 import (
+	"io"
 	"os"
-	"sync"
-	"sync/atomic"
 )
 
+// ColorMode 控制台输出是否按级别着色
+type ColorMode int
+
+const (
+	ColorAuto   ColorMode = iota // 输出到终端时着色,默认
+	ColorAlways                  // 总是着色
+	ColorNever                   // 不着色
+)
+
+const colorReset = "\x1b[0m"
+
+// 各级别的ANSI颜色
+var levelColors = [...]string{
+	PanicLevel: "\x1b[1;35m",
+	FatalLevel: "\x1b[1;31m",
+	ErrorLevel: "\x1b[31m",
+	WarnLevel:  "\x1b[33m",
+	InfoLevel:  "\x1b[36m",
+	DebugLevel: "\x1b[90m",
+}
+
+// WithColor 控制台输出的着色方式,默认ColorAuto
+func WithColor(mode ColorMode) WriterOption {
+	return func(o *writerOptions) {
+		o.color = mode
+	}
+}
+
+// WithStderr Error及以上级别是否输出到stderr,默认true
+func WithStderr(b bool) WriterOption {
+	return func(o *writerOptions) {
+		o.stderr = b
+	}
+}
+
 type StdWriter struct {
-	writeBuffer chan []byte
-	WgWrapper
-	sync.Once
-	closed int32
-	exit   chan struct{}
+	*lineQueue
+	stdout      io.Writer
+	stderr      io.Writer
+	colorStdout bool
+	colorStderr bool
 }
 
-func NewStdWriter() *StdWriter {
+func NewStdWriter(opts ...WriterOption) *StdWriter {
 	s := &StdWriter{
-		writeBuffer: make(chan []byte, 1<<10),
-		exit:        make(chan struct{}),
+		lineQueue: newLineQueue("stdout", opts),
+		stdout:    os.Stdout,
+		stderr:    os.Stderr,
 	}
+	s.colorStdout = s.useColor(os.Stdout)
+	s.colorStderr = s.useColor(os.Stderr)
 	s.Start()
 	return s
 }
 
+func (s *StdWriter) useColor(f *os.File) bool {
+	switch s.opt.color {
+	case ColorAlways:
+		return true
+	case ColorNever:
+		return false
+	}
+	return isTerminal(f)
+}
+
+// isTerminal 是否为字符设备,重定向到文件或管道时为false
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
 func (s *StdWriter) Start() {
-	s.Wrap(func() {
-		for {
-			select {
-			case <-s.exit:
-				return
-			case content := <-s.writeBuffer:
-				_, _ = os.Stdout.Write(content)
-			}
-		}
+	s.start(s)
+}
+
+func (s *StdWriter) writeLine(level Level, b []byte) {
+	w, color := s.stdout, s.colorStdout
+	if s.opt.stderr && level <= ErrorLevel {
+		w, color = s.stderr, s.colorStderr
+	}
+	if color && level.valid() {
+		b = colorize(level, b)
+	}
+	_, _ = w.Write(b)
+}
 
-	})
+// colorize 颜色不包含结尾的换行
+func colorize(level Level, b []byte) []byte {
+	n := len(b)
+	if n > 0 && b[n-1] == '\n' {
+		n--
+	}
+	colored := make([]byte, 0, len(b)+len(levelColors[level])+len(colorReset))
+	colored = append(colored, levelColors[level]...)
+	colored = append(colored, b[:n]...)
+	colored = append(colored, colorReset...)
+	return append(colored, b[n:]...)
 }
 
+func (s *StdWriter) flush() {}
+
 func (s *StdWriter) Close() {
-	s.Once.Do(func() {
-		atomic.StoreInt32(&s.closed, 1)
-		s.exit <- struct{}{}
-		s.Wait()
-	})
+	s.close()
 }
 
 func (s *StdWriter) Levels() []Level {
@@ -50,12 +115,11 @@ func (s *StdWriter) Levels() []Level {
 }
 
 func (s *StdWriter) LogWrite(b []byte) error {
-	//if isClose(s.writeBuffer) {
-	//	return nil
-	//}
-	if atomic.LoadInt32(&s.closed) == 1 {
-		return nil
-	}
-	s.writeBuffer <- b
+	s.push(InfoLevel, b)
+	return nil
+}
+
+func (s *StdWriter) LogWriteLevel(level Level, b []byte) error {
+	s.push(level, b)
 	return nil
 }
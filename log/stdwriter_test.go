@@ -0,0 +1,33 @@
+package log
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestStdWriterColorAndStderr(t *testing.T) {
+	for _, c := range []struct {
+		name           string
+		opts           []WriterOption
+		stdout, stderr string
+	}{
+		{"color", []WriterOption{WithColor(ColorAlways)},
+			"\x1b[36minfo\x1b[0m\n", "\x1b[31merror\x1b[0m\n"},
+		{"plain", []WriterOption{WithColor(ColorNever)},
+			"info\n", "error\n"},
+		{"no stderr", []WriterOption{WithColor(ColorNever), WithStderr(false)},
+			"info\nerror\n", ""},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			var stdout, stderr bytes.Buffer
+			s := NewStdWriter(c.opts...)
+			s.stdout, s.stderr = &stdout, &stderr
+			_ = s.LogWriteLevel(InfoLevel, []byte("info\n"))
+			_ = s.LogWriteLevel(ErrorLevel, []byte("error\n"))
+			s.Close()
+			if stdout.String() != c.stdout || stderr.String() != c.stderr {
+				t.Fatalf("stdout %q stderr %q", stdout.String(), stderr.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,139 @@
+package log
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+)
+
+// Facility syslog的facility,见RFC 5424 6.2.1
+type Facility int
+
+const (
+	FacilityKern   Facility = 0
+	FacilityUser   Facility = 1
+	FacilityDaemon Facility = 3
+	FacilityLocal0 Facility = 16
+	FacilityLocal1 Facility = 17
+	FacilityLocal2 Facility = 18
+	FacilityLocal3 Facility = 19
+	FacilityLocal4 Facility = 20
+	FacilityLocal5 Facility = 21
+	FacilityLocal6 Facility = 22
+	FacilityLocal7 Facility = 23
+)
+
+// 日志级别对应的syslog severity
+var severities = [...]int{
+	PanicLevel: 1, // alert
+	FatalLevel: 2, // crit
+	ErrorLevel: 3, // err
+	WarnLevel:  4, // warning
+	InfoLevel:  6, // info
+	DebugLevel: 7, // debug
+}
+
+const syslogTimeFormat = "2006-01-02T15:04:05.000000Z07:00"
+
+// SyslogWriter 以RFC 5424格式发送到syslog,udp/unixgram每行一个数据报,
+// tcp/unix按RFC 6587以长度前缀分帧,断线自动重连
+type SyslogWriter struct {
+	*lineQueue
+	conn     *redialer
+	stream   bool
+	facility Facility
+	hostname string
+	tag      string
+	pid      string
+	levels   []Level
+}
+
+func NewSyslogWriter(network, addr, tag string, facility Facility, opts ...WriterOption) (*SyslogWriter, error) {
+	var stream bool
+	switch network {
+	case "tcp", "tcp4", "tcp6", "unix":
+		stream = true
+	case "udp", "udp4", "udp6", "unixgram":
+	default:
+		return nil, fmt.Errorf("log: unsupported network %q for SyslogWriter", network)
+	}
+	hostname, err := os.Hostname()
+	if err != nil || hostname == "" {
+		hostname = "-"
+	}
+	if tag == "" {
+		tag = "-"
+	}
+	opts = append([]WriterOption{WithOverflow(OverflowDropNewest)}, opts...)
+	q := newLineQueue("syslog", opts)
+	w := &SyslogWriter{
+		lineQueue: q,
+		conn:      &redialer{network: network, addr: addr, limit: q.opt.bufferSize},
+		stream:    stream,
+		facility:  facility,
+		hostname:  hostname,
+		tag:       tag,
+		pid:       strconv.Itoa(os.Getpid()),
+		levels:    AllLevels,
+	}
+	w.Start()
+	return w, nil
+}
+
+func (w *SyslogWriter) Start() {
+	w.start(w)
+}
+
+// format <PRI>1 TIMESTAMP HOSTNAME APP-NAME PROCID MSGID SD MSG
+func (w *SyslogWriter) format(level Level, b []byte, now time.Time) []byte {
+	severity := severities[InfoLevel]
+	if level.valid() {
+		severity = severities[level]
+	}
+	msg := bytes.TrimRight(b, "\n")
+	header := fmt.Sprintf("<%d>1 %s %s %s %s - - ", int(w.facility)*8+severity,
+		now.Format(syslogTimeFormat), w.hostname, w.tag, w.pid)
+	if w.stream {
+		return []byte(fmt.Sprintf("%d %s%s", len(header)+len(msg), header, msg))
+	}
+	return append([]byte(header), msg...)
+}
+
+func (w *SyslogWriter) writeLine(level Level, b []byte) {
+	if !w.conn.send(w.format(level, b, time.Now())) {
+		w.drop()
+	}
+}
+
+// flush 定时重发断线期间保留的行
+func (w *SyslogWriter) flush() {
+	w.conn.retry()
+}
+
+func (w *SyslogWriter) SetLevels(levels []Level) {
+	w.levels = levels
+}
+
+func (w *SyslogWriter) Levels() []Level {
+	return w.levels
+}
+
+func (w *SyslogWriter) LogWrite(b []byte) error {
+	w.push(InfoLevel, b)
+	return nil
+}
+
+func (w *SyslogWriter) LogWriteLevel(level Level, b []byte) error {
+	w.push(level, b)
+	return nil
+}
+
+// Close 发送完缓存中的日志后断开连接
+func (w *SyslogWriter) Close() {
+	w.close()
+	for n := w.conn.close(); n > 0; n-- {
+		w.drop()
+	}
+}
@@ -0,0 +1,98 @@
+package log
+
+import (
+	"bytes"
+	"fmt"
+	"runtime"
+	"sort"
+	"strconv"
+)
+
+// TextFormatter 默认格式: [app] [INFO][时间] [文件:行号] 消息 key=value ...
+type TextFormatter struct {
+	TimestampFormat  string //默认SlashWithMillFormat
+	DisableTimestamp bool
+	DisableSorting   bool //字段默认按key排序
+	// CallerPrettyfier 自定义调用位置的输出,返回空字符串时不输出
+	CallerPrettyfier func(*runtime.Frame) string
+}
+
+func (f *TextFormatter) Format(entry *Entry) ([]byte, error) {
+	b := entry.Buffer
+	if b == nil {
+		b = &bytes.Buffer{}
+	}
+	if name := appName(entry); name != "" {
+		b.WriteString("[")
+		b.WriteString(name)
+		b.WriteString("] ")
+	}
+	b.WriteString(entry.Level.String())
+	if !f.DisableTimestamp {
+		timestampFormat := f.TimestampFormat
+		if timestampFormat == "" {
+			timestampFormat = SlashWithMillFormat
+		}
+		b.WriteString("[")
+		b.WriteString(entry.Time.Format(timestampFormat))
+		b.WriteString("]")
+	}
+	b.WriteString(" ")
+	if entry.HasCaller() {
+		caller := fmt.Sprintf("%s:%d", entry.Caller.File, entry.Caller.Line)
+		if f.CallerPrettyfier != nil {
+			caller = f.CallerPrettyfier(entry.Caller)
+		}
+		if caller != "" {
+			b.WriteString("[")
+			b.WriteString(caller)
+			b.WriteString("] ")
+		}
+	}
+	b.WriteString(entry.Message)
+
+	keys := make([]string, 0, len(entry.Data))
+	for k := range entry.Data {
+		keys = append(keys, k)
+	}
+	if !f.DisableSorting {
+		sort.Strings(keys)
+	}
+	for _, k := range keys {
+		b.WriteByte(' ')
+		b.WriteString(k)
+		b.WriteByte('=')
+		writeTextValue(b, entry.Data[k])
+	}
+	b.WriteByte('\n')
+	return b.Bytes(), nil
+}
+
+func writeTextValue(b *bytes.Buffer, value interface{}) {
+	var s string
+	switch v := value.(type) {
+	case string:
+		s = v
+	case error:
+		s = v.Error()
+	default:
+		s = fmt.Sprint(v)
+	}
+	if needsQuoting(s) {
+		b.WriteString(strconv.Quote(s))
+		return
+	}
+	b.WriteString(s)
+}
+
+func needsQuoting(s string) bool {
+	if s == "" {
+		return true
+	}
+	for _, c := range s {
+		if c <= ' ' || c == '"' || c == '=' || c > '~' {
+			return true
+		}
+	}
+	return false
+}
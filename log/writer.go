@@ -1,5 +1,8 @@
 package log
 
+// 写缓存满或关闭后写入而被丢弃的日志行数
+const metricDroppedLines = "jnet_log_dropped_lines_total"
+
 type LogWriter interface {
 	Levels() []Level
 	LogWrite(b []byte) error
@@ -14,9 +17,31 @@ func (w LevelWriters) Add(logWriter LogWriter) {
 }
 func (w LevelWriters) Fire(level Level, b []byte) error {
 	for _, hook := range w[level] {
-		if err := hook.LogWrite(b); err != nil {
+		var err error
+		if lw, ok := hook.(LevelLogWriter); ok {
+			err = lw.LogWriteLevel(level, b)
+		} else {
+			err = hook.LogWrite(b)
+		}
+		if err != nil {
 			return err
 		}
 	}
 	return nil
 }
+
+// unique 去重后的所有writer,同一writer可能注册在多个级别
+func (w LevelWriters) unique() []LogWriter {
+	var writers []LogWriter
+	seen := make(map[LogWriter]struct{})
+	for _, level := range AllLevels {
+		for _, lw := range w[level] {
+			if _, ok := seen[lw]; ok {
+				continue
+			}
+			seen[lw] = struct{}{}
+			writers = append(writers, lw)
+		}
+	}
+	return writers
+}
@@ -0,0 +1,216 @@
+package log
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMemoryWriter(t *testing.T) {
+	m := NewMemoryWriter(3)
+	for i := 0; i < 5; i++ {
+		_ = m.LogWrite([]byte(strconv.Itoa(i)))
+	}
+	if got := strings.Join(m.Lines(), ","); got != "2,3,4" {
+		t.Fatalf("Lines = %s", got)
+	}
+	if got := strings.Join(m.Tail(2), ","); got != "3,4" {
+		t.Fatalf("Tail(2) = %s", got)
+	}
+	m.Reset()
+	if len(m.Lines()) != 0 {
+		t.Fatal("lines left after Reset")
+	}
+}
+
+func syslogSuffix(tag string) string {
+	return fmt.Sprintf(" %s %d - - [ERROR] boom", tag, os.Getpid())
+}
+
+func TestSyslogWriterDatagram(t *testing.T) {
+	for _, network := range []string{"udp", "unixgram"} {
+		t.Run(network, func(t *testing.T) {
+			var conn net.PacketConn
+			var err error
+			if network == "udp" {
+				conn, err = net.ListenPacket("udp", "127.0.0.1:0")
+			} else {
+				conn, err = net.ListenPacket("unixgram", filepath.Join(t.TempDir(), "syslog.sock"))
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer conn.Close()
+			w, err := NewSyslogWriter(network, conn.LocalAddr().String(), "game", FacilityUser)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer w.Close()
+			_ = w.LogWriteLevel(ErrorLevel, []byte("[ERROR] boom\n"))
+
+			buf := make([]byte, 1024)
+			_ = conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+			n, _, err := conn.ReadFrom(buf)
+			if err != nil {
+				t.Fatal(err)
+			}
+			msg := string(buf[:n])
+			// facility user(1)*8 + severity err(3)
+			if !strings.HasPrefix(msg, "<11>1 ") || !strings.HasSuffix(msg, syslogSuffix("game")) {
+				t.Fatalf("unexpected syslog message %q", msg)
+			}
+		})
+	}
+}
+
+func TestSyslogWriterTCPFraming(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	w, err := NewSyslogWriter("tcp", ln.Addr().String(), "game", FacilityLocal0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+	_ = w.LogWriteLevel(ErrorLevel, []byte("[ERROR] boom\n"))
+
+	conn, err := ln.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	_ = conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	r := bufio.NewReader(conn)
+	size, err := r.ReadString(' ')
+	if err != nil {
+		t.Fatal(err)
+	}
+	n, err := strconv.Atoi(strings.TrimSpace(size))
+	if err != nil {
+		t.Fatalf("bad frame length %q", size)
+	}
+	msg := make([]byte, n)
+	if _, err := io.ReadFull(r, msg); err != nil {
+		t.Fatal(err)
+	}
+	// local0(16)*8 + err(3)
+	if !strings.HasPrefix(string(msg), "<131>1 ") || !strings.HasSuffix(string(msg), syslogSuffix("game")) {
+		t.Fatalf("unexpected syslog message %q", msg)
+	}
+}
+
+func TestNetWriterReconnect(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	conns := make(chan net.Conn, 4)
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			conns <- conn
+		}
+	}()
+	w, err := NewNetWriter("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+	readLine := func(conn net.Conn) string {
+		_ = conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+		line, _ := bufio.NewReader(conn).ReadString('\n')
+		return line
+	}
+
+	_ = w.LogWrite([]byte("first\n"))
+	first := <-conns
+	if line := readLine(first); line != "first\n" {
+		t.Fatalf("got %q", line)
+	}
+	// 收集端断开后继续写入,直到重连成功
+	_ = first.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	for {
+		_ = w.LogWrite([]byte("second\n"))
+		_ = w.Flush(ctx)
+		select {
+		case conn := <-conns:
+			defer conn.Close()
+			if line := readLine(conn); line != "second\n" {
+				t.Fatalf("got %q after reconnect", line)
+			}
+			return
+		case <-ctx.Done():
+			t.Fatal("NetWriter did not reconnect")
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+}
+
+// 收集端重启期间写入的行保留在缓存中,重连后按顺序送达
+func TestNetWriterKeepsLinesDuringOutage(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := ln.Addr().String()
+	w, err := NewNetWriter("tcp", addr, WithFlushInterval(10*time.Millisecond))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+
+	_ = w.LogWrite([]byte("first\n"))
+	first, err := ln.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := bufio.NewReader(first)
+	_ = first.SetReadDeadline(time.Now().Add(2 * time.Second))
+	if line, _ := r.ReadString('\n'); line != "first\n" {
+		t.Fatalf("got %q", line)
+	}
+	// 关闭收集端,RST使下一次写入立即失败
+	_ = ln.Close()
+	_ = first.(*net.TCPConn).SetLinger(0)
+	_ = first.Close()
+
+	want := []string{"a\n", "b\n", "c\n"}
+	for _, line := range want {
+		_ = w.LogWrite([]byte(line))
+	}
+	_ = w.Flush(context.Background())
+
+	ln, err = net.Listen("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	conn, err := ln.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	r = bufio.NewReader(conn)
+	_ = conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	for _, want := range want {
+		if line, _ := r.ReadString('\n'); line != want {
+			t.Fatalf("got %q after reconnect, want %q", line, want)
+		}
+	}
+}
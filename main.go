@@ -26,7 +26,10 @@ func test(request base.IRequest) bool {
 func main() {
 	tcpServer := tcp.NewServer("127.0.0.1:1440")
 	tcpServer.BindPacketFunc(test)
-	tcpServer.Serve()
+	if err := tcpServer.Serve(); err != nil {
+		fmt.Println("server start err, exit! ", err)
+		return
+	}
 	c := make(chan os.Signal, 1)
 
 	signal.Notify(c, os.Interrupt, os.Kill, syscall.SIGTERM)
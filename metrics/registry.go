@@ -0,0 +1,241 @@
+package metrics
+
+import (
+	"bufio"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+type kind int
+
+const (
+	kindCounter kind = iota
+	kindGauge
+	kindHistogram
+)
+
+var kindString = [...]string{"counter", "gauge", "histogram"}
+
+// DefaultBuckets 直方图默认分桶,单位秒
+var DefaultBuckets = []float64{.0001, .0005, .001, .005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+type series struct {
+	labels []string
+	value  float64
+	counts []uint64 //直方图各分桶计数,非累计
+	count  uint64
+}
+
+type family struct {
+	name    string
+	kind    kind
+	buckets []float64
+	series  map[string]*series
+}
+
+// Registry 内存中的Sink实现,按Prometheus文本格式输出
+type Registry struct {
+	mux      sync.Mutex
+	families map[string]*family
+	buckets  map[string][]float64
+}
+
+func NewRegistry() *Registry {
+	return &Registry{
+		families: make(map[string]*family),
+		buckets:  make(map[string][]float64),
+	}
+}
+
+// SetBuckets 指定直方图的分桶,需在第一次Observe之前设置
+func (r *Registry) SetBuckets(name string, buckets []float64) {
+	b := append([]float64(nil), buckets...)
+	sort.Float64s(b)
+	r.mux.Lock()
+	r.buckets[name] = b
+	r.mux.Unlock()
+}
+
+func (r *Registry) series(name string, k kind, labels []string) *series {
+	f, ok := r.families[name]
+	if !ok {
+		f = &family{name: name, kind: k, series: make(map[string]*series)}
+		if k == kindHistogram {
+			f.buckets = r.buckets[name]
+			if f.buckets == nil {
+				f.buckets = DefaultBuckets
+			}
+		}
+		r.families[name] = f
+	}
+	key := strings.Join(labels, "\xff")
+	s, ok := f.series[key]
+	if !ok {
+		s = &series{labels: append([]string(nil), labels...)}
+		if f.kind == kindHistogram {
+			s.counts = make([]uint64, len(f.buckets))
+		}
+		f.series[key] = s
+	}
+	return s
+}
+
+func (r *Registry) AddCounter(name string, v float64, labels ...string) {
+	r.mux.Lock()
+	r.series(name, kindCounter, labels).value += v
+	r.mux.Unlock()
+}
+
+func (r *Registry) SetGauge(name string, v float64, labels ...string) {
+	r.mux.Lock()
+	r.series(name, kindGauge, labels).value = v
+	r.mux.Unlock()
+}
+
+func (r *Registry) AddGauge(name string, v float64, labels ...string) {
+	r.mux.Lock()
+	r.series(name, kindGauge, labels).value += v
+	r.mux.Unlock()
+}
+
+func (r *Registry) Observe(name string, v float64, labels ...string) {
+	r.mux.Lock()
+	s := r.series(name, kindHistogram, labels)
+	for i, b := range r.families[name].buckets {
+		if v <= b {
+			s.counts[i]++
+			break
+		}
+	}
+	s.count++
+	s.value += v
+	r.mux.Unlock()
+}
+
+// Value 返回计数器或仪表的当前值,直方图返回观测总和
+func (r *Registry) Value(name string, labels ...string) float64 {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+	f, ok := r.families[name]
+	if !ok {
+		return 0
+	}
+	s, ok := f.series[strings.Join(labels, "\xff")]
+	if !ok {
+		return 0
+	}
+	return s.value
+}
+
+// WriteText 先执行已注册的Collector,再按文本格式输出全部指标
+func (r *Registry) WriteText(w io.Writer) error {
+	collected := NewRegistry()
+	r.mux.Lock()
+	for name, b := range r.buckets {
+		collected.buckets[name] = b
+	}
+	r.mux.Unlock()
+	Collect(collected)
+
+	bw := bufio.NewWriter(w)
+	r.mux.Lock()
+	families := make([]*family, 0, len(r.families)+len(collected.families))
+	for _, f := range r.families {
+		families = append(families, f)
+	}
+	for name, f := range collected.families {
+		if _, ok := r.families[name]; !ok {
+			families = append(families, f)
+		}
+	}
+	sort.Slice(families, func(i, j int) bool {
+		return families[i].name < families[j].name
+	})
+	for _, f := range families {
+		writeFamily(bw, f)
+	}
+	r.mux.Unlock()
+	return bw.Flush()
+}
+
+// ServeHTTP 以text/plain exposition格式输出,可直接挂到http.ServeMux
+func (r *Registry) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	_ = r.WriteText(w)
+}
+
+func writeFamily(w *bufio.Writer, f *family) {
+	w.WriteString("# TYPE ")
+	w.WriteString(f.name)
+	w.WriteString(" ")
+	w.WriteString(kindString[f.kind])
+	w.WriteString("\n")
+
+	keys := make([]string, 0, len(f.series))
+	for k := range f.series {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		s := f.series[k]
+		if f.kind != kindHistogram {
+			writeSample(w, f.name, s.labels, "", s.value)
+			continue
+		}
+		var cumulative uint64
+		for i, b := range f.buckets {
+			cumulative += s.counts[i]
+			writeSample(w, f.name+"_bucket", s.labels, formatFloat(b), float64(cumulative))
+		}
+		writeSample(w, f.name+"_bucket", s.labels, "+Inf", float64(s.count))
+		writeSample(w, f.name+"_sum", s.labels, "", s.value)
+		writeSample(w, f.name+"_count", s.labels, "", float64(s.count))
+	}
+}
+
+func writeSample(w *bufio.Writer, name string, labels []string, le string, v float64) {
+	w.WriteString(name)
+	if len(labels) > 1 || le != "" {
+		w.WriteString("{")
+		sep := ""
+		for i := 0; i+1 < len(labels); i += 2 {
+			w.WriteString(sep)
+			w.WriteString(labels[i])
+			w.WriteString(`="`)
+			w.WriteString(escapeLabel(labels[i+1]))
+			w.WriteString(`"`)
+			sep = ","
+		}
+		if le != "" {
+			w.WriteString(sep)
+			w.WriteString(`le="`)
+			w.WriteString(le)
+			w.WriteString(`"`)
+		}
+		w.WriteString("}")
+	}
+	w.WriteString(" ")
+	w.WriteString(formatFloat(v))
+	w.WriteString("\n")
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func escapeLabel(v string) string {
+	return labelEscaper.Replace(v)
+}
+
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
@@ -0,0 +1,75 @@
+package metrics
+
+import (
+	"io/ioutil"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRegistryHandler(t *testing.T) {
+	r := NewRegistry()
+	SetSink(r)
+	defer SetSink(nil)
+	r.SetBuckets("latency_seconds", []float64{0.1, 1})
+
+	AddCounter("msgs_total", 1, "msgid", "1")
+	AddCounter("msgs_total", 2, "msgid", "1")
+	AddCounter("msgs_total", 1, "msgid", "2")
+	AddGauge("sessions", 3)
+	AddGauge("sessions", -1)
+	Observe("latency_seconds", 0.05)
+	Observe("latency_seconds", 0.5)
+	Observe("latency_seconds", 5)
+	unregister := RegisterCollector(func(s Sink) {
+		s.SetGauge("queue_length", 7, "worker", `a"b`)
+	})
+	defer unregister()
+
+	srv := httptest.NewServer(r)
+	defer srv.Close()
+	resp, err := srv.Client().Get(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, _ := ioutil.ReadAll(resp.Body)
+	text := string(body)
+
+	for _, line := range []string{
+		"# TYPE msgs_total counter",
+		`msgs_total{msgid="1"} 3`,
+		`msgs_total{msgid="2"} 1`,
+		"# TYPE sessions gauge",
+		"sessions 2",
+		"# TYPE latency_seconds histogram",
+		`latency_seconds_bucket{le="0.1"} 1`,
+		`latency_seconds_bucket{le="1"} 2`,
+		`latency_seconds_bucket{le="+Inf"} 3`,
+		"latency_seconds_sum 5.55",
+		"latency_seconds_count 3",
+		`queue_length{worker="a\"b"} 7`,
+	} {
+		if !strings.Contains(text, line+"\n") {
+			t.Errorf("missing %q in output:\n%s", line, text)
+		}
+	}
+	if ct := resp.Header.Get("Content-Type"); !strings.HasPrefix(ct, "text/plain") {
+		t.Errorf("Content-Type = %q", ct)
+	}
+
+	unregister()
+	var out strings.Builder
+	_ = r.WriteText(&out)
+	if strings.Contains(out.String(), "queue_length") {
+		t.Error("collector still called after unregister")
+	}
+}
+
+func TestDisabledSink(t *testing.T) {
+	SetSink(nil)
+	if Enabled() {
+		t.Fatal("Enabled with nil sink")
+	}
+	AddCounter("ignored", 1)
+}
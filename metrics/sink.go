@@ -0,0 +1,131 @@
+package metrics
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// Sink 指标输出接口,labels为key,value交替的标签列表
+type Sink interface {
+	AddCounter(name string, v float64, labels ...string)
+	SetGauge(name string, v float64, labels ...string)
+	AddGauge(name string, v float64, labels ...string)
+	Observe(name string, v float64, labels ...string)
+}
+
+// Collector 拉取型指标,在输出前调用,适合队列长度等瞬时值
+type Collector func(s Sink)
+
+type nopSink struct{}
+
+func (nopSink) AddCounter(string, float64, ...string) {}
+func (nopSink) SetGauge(string, float64, ...string)   {}
+func (nopSink) AddGauge(string, float64, ...string)   {}
+func (nopSink) Observe(string, float64, ...string)    {}
+
+type sinkHolder struct {
+	Sink
+}
+
+var (
+	defaultSink atomic.Value //sinkHolder
+	enabled     int32
+
+	collectorMux sync.Mutex
+	collectorID  uint64
+	collectors   = make(map[uint64]Collector)
+)
+
+func init() {
+	defaultSink.Store(sinkHolder{nopSink{}})
+}
+
+// SetSink 设置全局输出,nil关闭指标
+func SetSink(s Sink) {
+	if s == nil {
+		atomic.StoreInt32(&enabled, 0)
+		defaultSink.Store(sinkHolder{nopSink{}})
+		return
+	}
+	defaultSink.Store(sinkHolder{s})
+	atomic.StoreInt32(&enabled, 1)
+}
+
+func DefaultSink() Sink {
+	return defaultSink.Load().(sinkHolder).Sink
+}
+
+// Enabled 未设置Sink时返回false,调用方可据此跳过标签的构造
+func Enabled() bool {
+	return atomic.LoadInt32(&enabled) == 1
+}
+
+func AddCounter(name string, v float64, labels ...string) {
+	DefaultSink().AddCounter(name, v, labels...)
+}
+
+func SetGauge(name string, v float64, labels ...string) {
+	DefaultSink().SetGauge(name, v, labels...)
+}
+
+func AddGauge(name string, v float64, labels ...string) {
+	DefaultSink().AddGauge(name, v, labels...)
+}
+
+func Observe(name string, v float64, labels ...string) {
+	DefaultSink().Observe(name, v, labels...)
+}
+
+// RegisterCollector 注册拉取型指标,返回注销函数
+func RegisterCollector(c Collector) (unregister func()) {
+	collectorMux.Lock()
+	collectorID++
+	id := collectorID
+	collectors[id] = c
+	collectorMux.Unlock()
+	return func() {
+		collectorMux.Lock()
+		delete(collectors, id)
+		collectorMux.Unlock()
+	}
+}
+
+// Collect 调用所有Collector输出到s
+func Collect(s Sink) {
+	collectorMux.Lock()
+	cs := make([]Collector, 0, len(collectors))
+	for _, c := range collectors {
+		cs = append(cs, c)
+	}
+	collectorMux.Unlock()
+	for _, c := range cs {
+		c(s)
+	}
+}
+
+// Fanout 同时输出到多个Sink
+type Fanout []Sink
+
+func (f Fanout) AddCounter(name string, v float64, labels ...string) {
+	for _, s := range f {
+		s.AddCounter(name, v, labels...)
+	}
+}
+
+func (f Fanout) SetGauge(name string, v float64, labels ...string) {
+	for _, s := range f {
+		s.SetGauge(name, v, labels...)
+	}
+}
+
+func (f Fanout) AddGauge(name string, v float64, labels ...string) {
+	for _, s := range f {
+		s.AddGauge(name, v, labels...)
+	}
+}
+
+func (f Fanout) Observe(name string, v float64, labels ...string) {
+	for _, s := range f {
+		s.Observe(name, v, labels...)
+	}
+}
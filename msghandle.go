@@ -2,8 +2,11 @@ package main
 
 import (
 	"fmt"
+	"jnet/metrics"
 	"jnet/network/base"
-	"runtime/debug"
+	"jnet/network/tcp"
+	"reflect"
+	"runtime"
 	"strconv"
 	"sync"
 )
@@ -26,7 +29,7 @@ func (w *worker) startWork() {
 		for {
 			select {
 			case task := <-w.taskQueue:
-				w.safeCall(task)
+				task()
 			case <-w.exitChan:
 				return
 			}
@@ -38,16 +41,6 @@ func (w *worker) curMsgChenLen() int {
 	return len(w.taskQueue)
 }
 
-func (w *worker) safeCall(f func()) {
-	defer func() {
-		if err := recover(); err != nil {
-			fmt.Println("err stack :", err)
-			debug.PrintStack()
-		}
-	}()
-	f()
-}
-
 func (w *worker) stopWork() {
 	w.exitChan <- struct{}{}
 }
@@ -58,14 +51,17 @@ func (w *worker) PostTask(f func()) {
 
 type MsgHandle struct {
 	mutex       sync.Mutex
+	server      *tcp.Server //处理函数panic时按server的方式恢复,见tcp.WithPanicHandler
 	size        uint64
 	taskChanNum int32
 	mHandlers   map[uint32]Handler
 	worker      []*worker
+	unregister  func()
 }
 
-func NewMsgHandle(size uint64, taskChanNum int32) *MsgHandle {
+func NewMsgHandle(server *tcp.Server, size uint64, taskChanNum int32) *MsgHandle {
 	return &MsgHandle{
+		server:      server,
 		size:        size,
 		taskChanNum: taskChanNum,
 		mHandlers:   map[uint32]Handler{},
@@ -80,7 +76,9 @@ func (mh *MsgHandle) DeliverMsg(request base.IRequest) {
 		return
 	}
 	mh.worker[workerID].PostTask(func() {
-		handler(request)
+		mh.server.SafeCall(request, func() {
+			handler(request)
+		})
 	})
 }
 
@@ -97,10 +95,42 @@ func (mh *MsgHandle) Start() {
 		mh.worker[i] = wk
 		wk.startWork()
 	}
+	mh.unregister = metrics.RegisterCollector(mh.collect)
 }
 
 func (mh *MsgHandle) Stop() {
+	if mh.unregister != nil {
+		mh.unregister()
+	}
 	for i := 0; i < int(mh.size); i++ {
 		mh.worker[i].stopWork()
 	}
 }
+
+type MsgHandleStats struct {
+	Workers  []int             `json:"workers"` //各工作协程队列长度
+	Handlers map[uint32]string `json:"handlers"`
+}
+
+// Stats 工作池状态及已注册的消息处理函数
+func (mh *MsgHandle) Stats() interface{} {
+	stats := MsgHandleStats{
+		Workers:  make([]int, 0, len(mh.worker)),
+		Handlers: make(map[uint32]string, len(mh.mHandlers)),
+	}
+	for _, wk := range mh.worker {
+		if wk != nil {
+			stats.Workers = append(stats.Workers, wk.curMsgChenLen())
+		}
+	}
+	for msgID, h := range mh.mHandlers {
+		stats.Handlers[msgID] = runtime.FuncForPC(reflect.ValueOf(h).Pointer()).Name()
+	}
+	return stats
+}
+
+func (mh *MsgHandle) collect(sink metrics.Sink) {
+	for i, wk := range mh.worker {
+		sink.SetGauge("jnet_msghandle_queue_length", float64(wk.curMsgChenLen()), "worker", strconv.Itoa(i))
+	}
+}
@@ -1,13 +1,17 @@
 package base
 
+import "context"
+
 type IRequest interface {
 	GetConnection() Session
 	GetData() []byte
 	GetMsgID() uint32
+	Context() context.Context
 }
 type Request struct {
 	Ses Session
 	Msg IMessage
+	Ctx context.Context //携带连接ID和消息ID等日志关联字段
 }
 
 func (r *Request) GetConnection() Session {
@@ -23,3 +27,11 @@ func (r *Request) GetData() []byte {
 func (r *Request) GetMsgID() uint32 {
 	return r.Msg.GetMsgID()
 }
+
+//Context 请求的上下文,未设置时为context.Background()
+func (r *Request) Context() context.Context {
+	if r.Ctx == nil {
+		return context.Background()
+	}
+	return r.Ctx
+}
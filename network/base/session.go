@@ -15,6 +15,42 @@ func (s *SessionIdentify) SetID(id uint64) {
 type Session interface {
 	ID() uint64
 	Close()
+	CloseWithReason(reason CloseReason)
+	CloseReason() CloseReason
 	Next(n int) []byte
 	Read() []byte
 }
+
+//连接关闭原因
+type CloseReason int32
+
+const (
+	CloseNone         CloseReason = iota
+	CloseReadError                //读取失败或对端关闭
+	CloseDecodeError              //解包失败
+	CloseSendOverflow             //发送队列已满
+	CloseFlood                    //超出接收频率限制
+	CloseServerStop               //服务器关闭
+	CloseActive                   //主动关闭
+	CloseKick                     //被管理端踢下线
+	ClosePanic                    //消息处理时发生panic
+)
+
+var closeReasonString = [...]string{
+	"none",
+	"read_error",
+	"decode_error",
+	"send_overflow",
+	"flood",
+	"server_stop",
+	"active",
+	"kick",
+	"panic",
+}
+
+func (r CloseReason) String() string {
+	if r < 0 || int(r) >= len(closeReasonString) {
+		return "unknown"
+	}
+	return closeReasonString[r]
+}
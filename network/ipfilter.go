@@ -0,0 +1,85 @@
+package network
+
+import (
+	"net"
+	"strings"
+)
+
+//IP黑白名单 支持单个IP或CIDR
+//allow为空时除deny外全部放行,否则只放行allow中的地址,deny优先
+type IPFilter struct {
+	allow []*net.IPNet
+	deny  []*net.IPNet
+}
+
+func NewIPFilter(allow, deny []string) (*IPFilter, error) {
+	f := new(IPFilter)
+	var err error
+	if f.allow, err = parseNets(allow); err != nil {
+		return nil, err
+	}
+	if f.deny, err = parseNets(deny); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+func parseNets(list []string) ([]*net.IPNet, error) {
+	nets := make([]*net.IPNet, 0, len(list))
+	for _, s := range list {
+		if !strings.Contains(s, "/") {
+			if ip := net.ParseIP(s); ip != nil && ip.To4() != nil {
+				s += "/32"
+			} else {
+				s += "/128"
+			}
+		}
+		_, ipNet, err := net.ParseCIDR(s)
+		if err != nil {
+			return nil, err
+		}
+		nets = append(nets, ipNet)
+	}
+	return nets, nil
+}
+
+func contains(nets []*net.IPNet, ip net.IP) bool {
+	for _, n := range nets {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+func (f *IPFilter) AllowIP(ip net.IP) bool {
+	if ip == nil {
+		return false
+	}
+	if contains(f.deny, ip) {
+		return false
+	}
+	if len(f.allow) == 0 {
+		return true
+	}
+	return contains(f.allow, ip)
+}
+
+// Allow 可直接作为tcp.WithOnAccept的回调
+func (f *IPFilter) Allow(conn net.Conn) bool {
+	return f.AllowIP(RemoteIP(conn))
+}
+
+func RemoteIP(conn net.Conn) net.IP {
+	switch addr := conn.RemoteAddr().(type) {
+	case *net.TCPAddr:
+		return addr.IP
+	case *net.UDPAddr:
+		return addr.IP
+	}
+	host, _, err := net.SplitHostPort(conn.RemoteAddr().String())
+	if err != nil {
+		return nil
+	}
+	return net.ParseIP(host)
+}
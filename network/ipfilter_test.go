@@ -0,0 +1,28 @@
+package network
+
+import (
+	"net"
+	"testing"
+)
+
+func TestIPFilter(t *testing.T) {
+	f, err := NewIPFilter([]string{"10.0.0.0/8", "192.168.1.7"}, []string{"10.0.1.0/24"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cases := map[string]bool{
+		"10.1.2.3":    true,
+		"10.0.1.5":    false,
+		"192.168.1.7": true,
+		"192.168.1.8": false,
+		"8.8.8.8":     false,
+	}
+	for ip, want := range cases {
+		if got := f.AllowIP(net.ParseIP(ip)); got != want {
+			t.Errorf("AllowIP(%s) = %v, want %v", ip, got, want)
+		}
+	}
+	if _, err := NewIPFilter([]string{"bad/ip"}, nil); err == nil {
+		t.Error("expected parse error")
+	}
+}
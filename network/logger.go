@@ -0,0 +1,17 @@
+package network
+
+// Logger 网络层日志接口,*log.Logger实现了该接口
+type Logger interface {
+	Debugf(format string, args ...interface{})
+	Infof(format string, args ...interface{})
+	Warnf(format string, args ...interface{})
+	Errorf(format string, args ...interface{})
+}
+
+// NopLogger 丢弃所有日志
+type NopLogger struct{}
+
+func (NopLogger) Debugf(string, ...interface{}) {}
+func (NopLogger) Infof(string, ...interface{})  {}
+func (NopLogger) Warnf(string, ...interface{})  {}
+func (NopLogger) Errorf(string, ...interface{}) {}
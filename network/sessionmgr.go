@@ -4,8 +4,20 @@ import (
 	"jnet/network/base"
 	"sync"
 	"sync/atomic"
+	"time"
 )
 
+// SessionInfo 连接状态快照,用于运维查看
+type SessionInfo struct {
+	ID         uint64    `json:"id"`
+	RemoteAddr string    `json:"remote_addr"`
+	CreatedAt  time.Time `json:"created_at"`
+	Age        float64   `json:"age_seconds"`
+	BytesIn    uint64    `json:"bytes_in"`
+	BytesOut   uint64    `json:"bytes_out"`
+	QueueDepth int       `json:"queue_depth"` //发送队列中等待的消息数
+}
+
 type SessionManager struct {
 	sessions sync.Map  //所有链接
 	Pool     sync.Pool //临时对象池
@@ -19,15 +31,21 @@ func (s *SessionManager) Store(id uint64, session interface{}) {
 func (s *SessionManager) GetIncrID() uint64 {
 	return atomic.AddUint64(&s.Incr, 1)
 }
+func (s *SessionManager) Get(id uint64) (interface{}, bool) {
+	return s.sessions.Load(id)
+}
+
 func (s *SessionManager) Del(id uint64) {
 	s.sessions.Delete(id)
 }
 
+// ClearConn 关闭所有连接,连接在各自回收时从管理器中删除
 func (s *SessionManager) ClearConn() {
 	s.sessions.Range(func(key, value interface{}) bool {
 		ses, ok := value.(base.Session)
 		if ok {
-			ses.Close()
+			ses.CloseWithReason(base.CloseServerStop)
+			return true
 		}
 		keyUint64, ok := key.(uint64)
 		if ok {
@@ -36,3 +54,9 @@ func (s *SessionManager) ClearConn() {
 		return true
 	})
 }
+
+func (s *SessionManager) Range(f func(id uint64, session interface{}) bool) {
+	s.sessions.Range(func(key, value interface{}) bool {
+		return f(key.(uint64), value)
+	})
+}
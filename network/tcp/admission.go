@@ -0,0 +1,117 @@
+package tcp
+
+import (
+	"jnet/base/ratelimit"
+	"jnet/metrics"
+	"jnet/network"
+	"net"
+	"sync"
+)
+
+// 连接准入控制: 总连接数、单IP连接数、accept速率、自定义回调
+type admission struct {
+	opt     *SvrOpt
+	connSem chan struct{}
+	limiter *ratelimit.Bucket
+	mux     sync.Mutex
+	ipConns map[string]int
+}
+
+func newAdmission(opt *SvrOpt) *admission {
+	a := &admission{
+		opt:     opt,
+		ipConns: make(map[string]int),
+	}
+	if opt.maxConn > 0 {
+		a.connSem = make(chan struct{}, opt.maxConn)
+	}
+	if opt.acceptRate > 0 {
+		a.limiter = ratelimit.NewBucketWithClock(opt.acceptRate, float64(opt.acceptBurst), opt.now)
+	}
+	return a
+}
+
+// accept之前调用,限速及暂停模式下等待连接名额,服务器退出时归还令牌并返回false
+func (a *admission) beforeAccept(exit <-chan struct{}) bool {
+	if a.limiter != nil {
+		if d := a.limiter.Reserve(1); d > 0 {
+			select {
+			case <-a.opt.after(d):
+			case <-exit:
+				a.limiter.Cancel(1)
+				return false
+			}
+		}
+	}
+	if a.connSem != nil && a.opt.pauseOnMaxConn {
+		select {
+		case a.connSem <- struct{}{}:
+		case <-exit:
+			if a.limiter != nil {
+				a.limiter.Cancel(1)
+			}
+			return false
+		}
+	}
+	return true
+}
+
+// accept失败时归还beforeAccept占用的名额
+func (a *admission) cancelAccept() {
+	if a.connSem != nil && a.opt.pauseOnMaxConn {
+		<-a.connSem
+	}
+}
+
+// 判断连接是否允许接入,返回连接占用的IP标识,拒绝时已释放所有占用
+func (a *admission) admit(conn net.Conn) (ip string, ok bool) {
+	if a.opt.onAccept != nil && !a.opt.onAccept(conn) {
+		a.cancelAccept()
+		metrics.AddCounter(metricAcceptRejects, 1, "reason", "on_accept")
+		return "", false
+	}
+	if a.connSem != nil && !a.opt.pauseOnMaxConn {
+		select {
+		case a.connSem <- struct{}{}:
+		default:
+			metrics.AddCounter(metricAcceptRejects, 1, "reason", "max_conn")
+			return "", false
+		}
+	}
+	if a.opt.maxConnPerIP > 0 {
+		remoteIP := network.RemoteIP(conn)
+		if remoteIP != nil {
+			ip = remoteIP.String()
+		}
+		a.mux.Lock()
+		if a.ipConns[ip] >= a.opt.maxConnPerIP {
+			a.mux.Unlock()
+			a.releaseConn()
+			metrics.AddCounter(metricAcceptRejects, 1, "reason", "max_conn_per_ip")
+			return "", false
+		}
+		a.ipConns[ip]++
+		a.mux.Unlock()
+	}
+	return ip, true
+}
+
+func (a *admission) releaseConn() {
+	if a.connSem != nil {
+		<-a.connSem
+	}
+}
+
+// 连接关闭时释放
+func (a *admission) release(ip string) {
+	if a.opt.maxConnPerIP > 0 {
+		a.mux.Lock()
+		if a.ipConns[ip] <= 1 {
+			delete(a.ipConns, ip)
+		} else {
+			a.ipConns[ip]--
+		}
+		a.mux.Unlock()
+	}
+	a.releaseConn()
+}
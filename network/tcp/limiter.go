@@ -0,0 +1,123 @@
+package tcp
+
+import (
+	"errors"
+	"jnet/base/ratelimit"
+	"jnet/network/base"
+	"time"
+)
+
+var errFlood = errors.New("inbound rate limit exceeded")
+
+type FloodAction int
+
+const (
+	FloodDrop       FloodAction = iota //丢弃超出限制的消息
+	FloodDelay                         //延迟处理,读协程阻塞直到取得令牌
+	FloodDisconnect                    //断开连接,关闭原因为base.CloseFlood
+)
+
+// 接收频率限制
+type RateLimit struct {
+	MsgPerSec   float64 //每秒消息数上限,0不限制
+	BytesPerSec float64 //每秒字节数上限,0不限制
+	Burst       float64 //允许突发的秒数,默认1秒
+}
+
+func (r RateLimit) enabled() bool {
+	return r.MsgPerSec > 0 || r.BytesPerSec > 0
+}
+
+func (r RateLimit) newBuckets(now func() time.Time) (msg, bytes *ratelimit.Bucket) {
+	burst := r.Burst
+	if burst <= 0 {
+		burst = 1
+	}
+	if r.MsgPerSec > 0 {
+		msg = ratelimit.NewBucketWithClock(r.MsgPerSec, r.MsgPerSec*burst, now)
+	}
+	if r.BytesPerSec > 0 {
+		bytes = ratelimit.NewBucketWithClock(r.BytesPerSec, r.BytesPerSec*burst, now)
+	}
+	return
+}
+
+type limitBuckets struct {
+	msg   *ratelimit.Bucket
+	bytes *ratelimit.Bucket
+}
+
+func (b *limitBuckets) take(size float64) bool {
+	if b.msg != nil && !b.msg.Take(1) {
+		return false
+	}
+	if b.bytes != nil && !b.bytes.Take(size) {
+		return false
+	}
+	return true
+}
+
+func (b *limitBuckets) wait(size float64, sleep func(time.Duration)) {
+	var d time.Duration
+	if b.msg != nil {
+		d = b.msg.Reserve(1)
+	}
+	if b.bytes != nil {
+		if bd := b.bytes.Reserve(size); bd > d {
+			d = bd
+		}
+	}
+	if d > 0 {
+		sleep(d)
+	}
+}
+
+// 单个session的接收限制,只在读协程中使用
+type inboundLimiter struct {
+	opt    *SvrOpt
+	global limitBuckets
+	perMsg map[uint32]*limitBuckets //指定msgID的独立限制,不计入global
+}
+
+func newInboundLimiter(opt *SvrOpt) *inboundLimiter {
+	if !opt.inboundLimit.enabled() && len(opt.msgLimits) == 0 {
+		return nil
+	}
+	l := &inboundLimiter{opt: opt}
+	l.global.msg, l.global.bytes = opt.inboundLimit.newBuckets(opt.now)
+	return l
+}
+
+func (l *inboundLimiter) buckets(msgID uint32) *limitBuckets {
+	limit, ok := l.opt.msgLimits[msgID]
+	if !ok {
+		return &l.global
+	}
+	if l.perMsg == nil {
+		l.perMsg = make(map[uint32]*limitBuckets)
+	}
+	b, ok := l.perMsg[msgID]
+	if !ok {
+		b = new(limitBuckets)
+		b.msg, b.bytes = limit.newBuckets(l.opt.now)
+		l.perMsg[msgID] = b
+	}
+	return b
+}
+
+// 返回false表示消息需要丢弃
+func (l *inboundLimiter) allow(msg base.IMessage) (bool, error) {
+	b := l.buckets(msg.GetMsgID())
+	size := float64(msg.GetDataLen())
+	if l.opt.floodAction == FloodDelay {
+		b.wait(size, l.opt.sleep)
+		return true, nil
+	}
+	if b.take(size) {
+		return true, nil
+	}
+	if l.opt.floodAction == FloodDisconnect {
+		return false, errFlood
+	}
+	return false, nil
+}
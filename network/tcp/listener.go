@@ -7,7 +7,7 @@ import (
 )
 
 type listener struct {
-	ln   *net.TCPListener
+	ln   net.Listener
 	net  string
 	addr string
 }
@@ -0,0 +1,63 @@
+package tcp
+
+import (
+	"jnet/metrics"
+	"strconv"
+	"time"
+)
+
+const (
+	metricSessionsActive  = "jnet_sessions_active"
+	metricAccepts         = "jnet_accepts_total"
+	metricAcceptRejects   = "jnet_accept_rejects_total"
+	metricSessionCloses   = "jnet_session_closes_total"
+	metricMessagesIn      = "jnet_messages_in_total"
+	metricBytesIn         = "jnet_bytes_in_total"
+	metricMessagesOut     = "jnet_messages_out_total"
+	metricBytesOut        = "jnet_bytes_out_total"
+	metricSendQueueDepth  = "jnet_send_queue_depth"
+	metricHandlerDuration = "jnet_handler_duration_seconds"
+)
+
+func msgIDLabel(msgID uint32) string {
+	return strconv.FormatUint(uint64(msgID), 10)
+}
+
+func countRecv(msgID uint32, size uint32) {
+	if !metrics.Enabled() {
+		return
+	}
+	id := msgIDLabel(msgID)
+	metrics.AddCounter(metricMessagesIn, 1, "msgid", id)
+	metrics.AddCounter(metricBytesIn, float64(size), "msgid", id)
+}
+
+func countSend(msgID uint32, size int) {
+	if !metrics.Enabled() {
+		return
+	}
+	id := msgIDLabel(msgID)
+	metrics.AddCounter(metricMessagesOut, 1, "msgid", id)
+	metrics.AddCounter(metricBytesOut, float64(size), "msgid", id)
+}
+
+func observeHandler(msgID uint32, start time.Time) {
+	if !metrics.Enabled() {
+		return
+	}
+	metrics.Observe(metricHandlerDuration, time.Since(start).Seconds(), "msgid", msgIDLabel(msgID))
+}
+
+// collect 采集时读取在线session数及所有session发送队列中等待的消息数
+func (s *Server) collect(sink metrics.Sink) {
+	var active, depth int
+	s.SessionManager.Range(func(id uint64, ses interface{}) bool {
+		active++
+		if v, ok := ses.(*session); ok {
+			depth += len(v.msgChan)
+		}
+		return true
+	})
+	sink.AddGauge(metricSessionsActive, float64(active))
+	sink.AddGauge(metricSendQueueDepth, float64(depth))
+}
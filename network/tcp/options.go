@@ -1,7 +1,10 @@
 package tcp
 
 import (
+	"jnet/log"
+	"jnet/network"
 	"jnet/network/base"
+	"net"
 	"time"
 )
 
@@ -11,13 +14,38 @@ type SvrOpt struct {
 	keepTcpAlive      time.Duration
 	receiveBufferSize int //单次接收缓存
 	Codec             base.Codec
+	maxConn           int                  //最大连接数
+	pauseOnMaxConn    bool                 //达到最大连接数时暂停accept,否则直接拒绝
+	maxConnPerIP      int                  //单IP最大并发连接数
+	acceptRate        float64              //每秒accept上限
+	acceptBurst       int                  //accept突发量
+	onAccept          func(net.Conn) bool  //自定义准入,返回false拒绝连接
+	inboundLimit      RateLimit            //单session接收限制
+	msgLimits         map[uint32]RateLimit //指定msgID的接收限制
+	floodAction       FloodAction          //超出接收限制时的处理方式
+	errorHandler      func(error)          //accept失败及服务器停止时回调
+	panicHandler      PanicHandler         //消息处理panic时回调,默认输出到logger
+	closeOnPanic      bool                 //消息处理panic后关闭连接
+	logger            network.Logger       //默认使用jnet/log的network模块
+	connLog           bool                 //是否输出单个连接的日志
+	now               func() time.Time     //限速使用的时钟,测试中替换
+	sleep             func(time.Duration)
+	after             func(time.Duration) <-chan time.Time //accept限速等待,可被服务器退出打断
 }
 
 func loadAllOptions(ops ...Option) *SvrOpt {
-	opts := new(SvrOpt)
+	opts := &SvrOpt{
+		connLog: true,
+		now:     time.Now,
+		sleep:   time.Sleep,
+		after:   time.After,
+	}
 	for _, op := range ops {
 		op(opts)
 	}
+	if opts.logger == nil {
+		opts.logger = log.Module("network")
+	}
 	return opts
 
 }
@@ -39,3 +67,87 @@ func WithCodec(codec base.Codec) Option {
 		s.Codec = codec
 	}
 }
+
+// WithMaxConn 最大连接数,pause为true时达到上限后暂停accept直到有连接释放,否则accept后立即关闭
+func WithMaxConn(n int, pause bool) Option {
+	return func(s *SvrOpt) {
+		s.maxConn = n
+		s.pauseOnMaxConn = pause
+	}
+}
+
+func WithMaxConnPerIP(n int) Option {
+	return func(s *SvrOpt) {
+		s.maxConnPerIP = n
+	}
+}
+
+// WithAcceptRate 限制每秒accept的连接数,超出的连接留在内核backlog中等待
+func WithAcceptRate(perSecond float64, burst int) Option {
+	return func(s *SvrOpt) {
+		s.acceptRate = perSecond
+		s.acceptBurst = burst
+	}
+}
+
+// WithOnAccept 自定义准入回调,可配合network.IPFilter实现CIDR黑白名单
+func WithOnAccept(f func(conn net.Conn) bool) Option {
+	return func(s *SvrOpt) {
+		s.onAccept = f
+	}
+}
+
+func WithInboundLimit(limit RateLimit) Option {
+	return func(s *SvrOpt) {
+		s.inboundLimit = limit
+	}
+}
+
+// WithMsgLimit 为指定msgID单独限制,该消息不再计入WithInboundLimit的全局限制
+func WithMsgLimit(msgID uint32, limit RateLimit) Option {
+	return func(s *SvrOpt) {
+		if s.msgLimits == nil {
+			s.msgLimits = make(map[uint32]RateLimit)
+		}
+		s.msgLimits[msgID] = limit
+	}
+}
+
+func WithFloodAction(action FloodAction) Option {
+	return func(s *SvrOpt) {
+		s.floodAction = action
+	}
+}
+
+// WithErrorHandler accept出现非临时错误及服务器因重新监听失败而停止时回调
+func WithErrorHandler(f func(err error)) Option {
+	return func(s *SvrOpt) {
+		s.errorHandler = f
+	}
+}
+
+func WithPanicHandler(h PanicHandler) Option {
+	return func(s *SvrOpt) {
+		s.panicHandler = h
+	}
+}
+
+// WithCloseOnPanic 消息处理panic后关闭对应连接,关闭原因为base.ClosePanic
+func WithCloseOnPanic(b bool) Option {
+	return func(s *SvrOpt) {
+		s.closeOnPanic = b
+	}
+}
+
+func WithLogger(l network.Logger) Option {
+	return func(s *SvrOpt) {
+		s.logger = l
+	}
+}
+
+// WithConnLog 关闭后不再输出连接建立、断开、解包失败等单个连接的日志
+func WithConnLog(b bool) Option {
+	return func(s *SvrOpt) {
+		s.connLog = b
+	}
+}
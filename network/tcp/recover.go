@@ -0,0 +1,57 @@
+package tcp
+
+import (
+	"jnet/metrics"
+	"jnet/network/base"
+	"runtime/debug"
+)
+
+const metricHandlerPanics = "jnet_handler_panics_total"
+
+// PanicHandler 消息处理函数panic时回调,stack为panic处的调用栈,未设置时输出到Server的日志
+type PanicHandler func(req base.IRequest, err interface{}, stack []byte)
+
+// SafeCall 执行f,panic时与Server上的消息处理函数一样计数、回调PanicHandler并按WithCloseOnPanic关闭连接,
+// 用于在工作协程中异步处理消息
+func (s *Server) SafeCall(req base.IRequest, f func()) {
+	defer func() {
+		if err := recover(); err != nil {
+			s.onPanic(req, err)
+		}
+	}()
+	f()
+}
+
+// 单个请求的panic不影响读协程,返回true停止后续回调
+func (s *Server) safeCall(f PacketFunc, req base.IRequest) (handled bool) {
+	defer func() {
+		if err := recover(); err != nil {
+			s.onPanic(req, err)
+			handled = true
+		}
+	}()
+	return f(req)
+}
+
+func (s *Server) onPanic(req base.IRequest, err interface{}) {
+	stack := debug.Stack()
+	if metrics.Enabled() {
+		metrics.AddCounter(metricHandlerPanics, 1, "msgid", msgIDLabel(req.GetMsgID()))
+	}
+	if s.panicHandler != nil {
+		s.panicHandler(req, err, stack)
+	} else {
+		s.logger.Errorf("handler panic: session=%d remote=%s msgID=%d: %v\n%s",
+			req.GetConnection().ID(), remoteAddr(req.GetConnection()), req.GetMsgID(), err, stack)
+	}
+	if s.closeOnPanic {
+		req.GetConnection().CloseWithReason(base.ClosePanic)
+	}
+}
+
+func remoteAddr(ses base.Session) string {
+	if v, ok := ses.(*session); ok {
+		return v.remote
+	}
+	return ""
+}
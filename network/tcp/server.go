@@ -2,16 +2,29 @@ package tcp
 
 import (
 	"encoding/binary"
-	"fmt"
+	"errors"
 	"jnet/base/vector"
+	"jnet/metrics"
 	"jnet/network"
 	"jnet/network/base"
+	"net"
+	"reflect"
+	"runtime"
+	"sort"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
 type PacketFunc func(request base.IRequest) bool //回调函数
-const Internal = 10 * time.Second
+const Internal = 10 * time.Second                //监听失效后重新监听的间隔
+
+var errServerClosed = errors.New("tcp: server closed")
+
+const (
+	minAcceptDelay = 5 * time.Millisecond //临时错误退避初始时间
+	maxAcceptDelay = time.Second          //临时错误退避最长时间
+)
 
 type Server struct {
 	l *listener
@@ -19,6 +32,13 @@ type Server struct {
 	network.SessionManager
 	protoAddr      string
 	packetFuncList *vector.Vector
+	admission      *admission
+	mux            sync.Mutex
+	closed         int32
+	exit           chan struct{}
+	done           chan struct{}
+	err            error
+	unregister     func() //注销指标采集
 }
 
 func NewServer(protoAddr string, opt ...Option) *Server {
@@ -26,6 +46,9 @@ func NewServer(protoAddr string, opt ...Option) *Server {
 	svr.SvrOpt = loadAllOptions(opt...)
 	svr.protoAddr = protoAddr
 	svr.packetFuncList = vector.NewVector()
+	svr.admission = newAdmission(svr.SvrOpt)
+	svr.exit = make(chan struct{})
+	svr.done = make(chan struct{})
 	svr.SessionManager = network.SessionManager{
 		Pool: sync.Pool{
 			New: func() interface{} {
@@ -42,17 +65,89 @@ func NewServer(protoAddr string, opt ...Option) *Server {
 	return svr
 }
 
-func (s *Server) Serve() {
-	go func() {
-		for {
-			err := s.startListen()
-			if err != nil {
-				return
+// Serve 同步监听,监听成功后在后台accept并返回nil
+// accept出现非临时错误时关闭旧监听,间隔Internal后重新监听,重新监听失败则服务器停止
+func (s *Server) Serve() error {
+	if err := s.startListen(); err != nil {
+		return err
+	}
+	s.unregister = metrics.RegisterCollector(s.collect)
+	go s.serve()
+	return nil
+}
+
+// ListenAndServe 阻塞直到服务器停止,调用Close停止时返回nil
+func (s *Server) ListenAndServe() error {
+	if err := s.Serve(); err != nil {
+		return err
+	}
+	<-s.Done()
+	return s.Err()
+}
+
+// Done 服务器停止后关闭
+func (s *Server) Done() <-chan struct{} {
+	return s.done
+}
+
+// Err 服务器停止的原因,Done关闭之前或通过Close停止时为nil
+func (s *Server) Err() error {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	return s.err
+}
+
+// Addr 当前监听地址,未监听时为nil
+func (s *Server) Addr() net.Addr {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	if s.l == nil {
+		return nil
+	}
+	return s.l.ln.Addr()
+}
+
+func (s *Server) serve() {
+	var err error
+	for {
+		err = s.startAccept()
+		if s.isClosed() {
+			err = nil
+			break
+		}
+		s.reportError(err)
+		s.closeListener()
+		select {
+		case <-time.After(Internal):
+		case <-s.exit:
+		}
+		if s.isClosed() {
+			break
+		}
+		if err = s.startListen(); err != nil {
+			if s.isClosed() {
+				err = nil
 			}
-			s.startAccept()
-			time.Sleep(Internal)
+			break
 		}
-	}()
+	}
+	if err != nil {
+		s.reportError(err)
+	}
+	s.mux.Lock()
+	s.err = err
+	s.mux.Unlock()
+	close(s.done)
+}
+
+func (s *Server) reportError(err error) {
+	if s.errorHandler != nil {
+		s.errorHandler(err)
+	}
+}
+
+func (s *Server) isClosed() bool {
+	return atomic.LoadInt32(&s.closed) == 1
 }
 
 func (s *Server) startListen() error {
@@ -60,36 +155,131 @@ func (s *Server) startListen() error {
 	if err != nil {
 		return err
 	}
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	//Close在监听期间执行时不会关闭新的监听
+	if s.isClosed() {
+		_ = l.ln.Close()
+		return errServerClosed
+	}
 	s.l = l
 	return nil
 }
 
+func (s *Server) closeListener() {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	if s.l != nil {
+		_ = s.l.ln.Close()
+	}
+}
+
 func (s *Server) BindPacketFunc(callfunc PacketFunc) {
 	s.packetFuncList.PushBack(callfunc)
 }
 
+// Handlers 已绑定回调的函数名,按调用顺序
+func (s *Server) Handlers() []string {
+	values := s.packetFuncList.Values()
+	names := make([]string, 0, len(values))
+	for _, v := range values {
+		names = append(names, runtime.FuncForPC(reflect.ValueOf(v).Pointer()).Name())
+	}
+	return names
+}
+
+// Sessions 当前所有连接的状态,按ID排序
+func (s *Server) Sessions() []network.SessionInfo {
+	var infos []network.SessionInfo
+	s.SessionManager.Range(func(id uint64, ses interface{}) bool {
+		if v, ok := ses.(*session); ok {
+			infos = append(infos, v.info())
+		}
+		return true
+	})
+	sort.Slice(infos, func(i, j int) bool {
+		return infos[i].ID < infos[j].ID
+	})
+	return infos
+}
+
+// Kick 关闭指定连接,关闭原因为base.CloseKick
+func (s *Server) Kick(id uint64) bool {
+	ses, ok := s.SessionManager.Get(id)
+	if !ok {
+		return false
+	}
+	ses.(*session).CloseWithReason(base.CloseKick)
+	return true
+}
+
 func (s *Server) HandlePacket(req base.IRequest) {
+	start := time.Now()
+	defer observeHandler(req.GetMsgID(), start)
 	for _, v := range s.packetFuncList.Values() {
-		if v.(PacketFunc)(req) {
+		if s.safeCall(v.(PacketFunc), req) {
 			break
 		}
 	}
 }
 
-func (s *Server) startAccept() {
+// 临时错误(如EMFILE)按net/http的方式退避重试,其他错误返回
+func (s *Server) startAccept() error {
+	s.mux.Lock()
+	ln := s.l.ln
+	s.mux.Unlock()
+	var tempDelay time.Duration
 	for {
-		conn, err := s.l.ln.AcceptTCP()
+		if !s.admission.beforeAccept(s.exit) {
+			return nil
+		}
+		conn, err := ln.Accept()
 		if err != nil {
-			fmt.Println("Accept err ", err)
-			break
+			s.admission.cancelAccept()
+			if s.isClosed() {
+				return err
+			}
+			if ne, ok := err.(net.Error); ok && ne.Temporary() {
+				if tempDelay == 0 {
+					tempDelay = minAcceptDelay
+				} else {
+					tempDelay *= 2
+				}
+				if tempDelay > maxAcceptDelay {
+					tempDelay = maxAcceptDelay
+				}
+				s.logger.Warnf("accept error: %v; retrying in %v", err, tempDelay)
+				select {
+				case <-time.After(tempDelay):
+				case <-s.exit:
+				}
+				continue
+			}
+			s.logger.Errorf("accept error: %v", err)
+			return err
+		}
+		tempDelay = 0
+		metrics.AddCounter(metricAccepts, 1)
+		ip, ok := s.admission.admit(conn)
+		if !ok {
+			_ = conn.Close()
+			continue
 		}
 		ses := newSession(conn, s)
+		ses.ip = ip
 		ses.Start()
 	}
 }
 
 func (s *Server) Close() {
-	_ = s.l.ln.Close()
+	if !atomic.CompareAndSwapInt32(&s.closed, 0, 1) {
+		return
+	}
+	close(s.exit)
+	if s.unregister != nil {
+		s.unregister()
+	}
+	s.closeListener()
 	s.SessionManager.ClearConn()
 }
 
@@ -97,8 +287,11 @@ func (s *Server) recycleSession(session *session) {
 	s.HandlePacket(&base.Request{
 		Ses: session,
 		Msg: base.NewMsgPackage(base.SessionClose, nil),
+		Ctx: session.ctx,
 	})
 	session.Close()
+	metrics.AddCounter(metricSessionCloses, 1, "reason", session.CloseReason().String())
+	s.admission.release(session.ip)
 	s.Del(session.ID())
-	s.Pool.Put(session)
+	//Sessions、Kick及读写协程可能仍持有该session,不放回Pool复用
 }
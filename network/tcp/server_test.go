@@ -0,0 +1,702 @@
+package tcp
+
+import (
+	"encoding/binary"
+	"fmt"
+	"jnet/log"
+	"jnet/metrics"
+	"jnet/network/base"
+	"net"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+const floodMsgID = 100
+
+var testParser = &base.PacketParser{
+	PacketHeadLen: 8,
+	MaxPacketLen:  40960,
+	ByteOrder:     binary.BigEndian,
+}
+
+func startTestServer(t *testing.T, s *Server) {
+	if err := s.Serve(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		s.Close()
+		waitRecycled(s)
+	})
+}
+
+// 等待所有session回收,避免影响后续测试的指标
+func waitRecycled(s *Server) {
+	for deadline := time.Now().Add(time.Second); len(s.Sessions()) > 0 && time.Now().Before(deadline); {
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func dialTestServer(t *testing.T, s *Server) net.Conn {
+	conn, err := net.Dial("tcp4", s.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = conn.Close() })
+	return conn
+}
+
+// 一次性写入n个消息
+func flood(t *testing.T, conn net.Conn, msgID uint32, n int, size int) {
+	var buf []byte
+	for i := 0; i < n; i++ {
+		raw, err := testParser.Encode(base.NewMsgPackage(msgID, make([]byte, size)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		buf = append(buf, raw...)
+	}
+	if _, err := conn.Write(buf); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// 发送完flood后发送floodDoneID,读协程按顺序处理,收到时之前的消息都已处理
+const floodDoneID = 999
+
+// fakeClock 限速使用的时钟,只在sleep时前进
+type fakeClock struct {
+	mux   sync.Mutex
+	t     time.Time
+	slept time.Duration
+}
+
+func (c *fakeClock) now() time.Time {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	return c.t
+}
+
+func (c *fakeClock) sleep(d time.Duration) {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	c.t = c.t.Add(d)
+	c.slept += d
+}
+
+func (c *fakeClock) after(d time.Duration) <-chan time.Time {
+	c.sleep(d)
+	ch := make(chan time.Time, 1)
+	ch <- c.now()
+	return ch
+}
+
+func (c *fakeClock) totalSlept() time.Duration {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	return c.slept
+}
+
+func withFakeClock(c *fakeClock) Option {
+	return func(s *SvrOpt) {
+		s.now = c.now
+		s.sleep = c.sleep
+		s.after = c.after
+	}
+}
+
+type floodCounter struct {
+	handled int64
+	done    chan struct{}
+	closed  chan base.CloseReason
+}
+
+func newFloodCounter(s *Server) *floodCounter {
+	c := &floodCounter{done: make(chan struct{}, 1), closed: make(chan base.CloseReason, 1)}
+	s.BindPacketFunc(func(req base.IRequest) bool {
+		switch req.GetMsgID() {
+		case base.SessionClose:
+			c.closed <- req.GetConnection().CloseReason()
+		case base.SessionConnect:
+		case floodDoneID:
+			c.done <- struct{}{}
+		default:
+			atomic.AddInt64(&c.handled, 1)
+		}
+		return true
+	})
+	return c
+}
+
+func (c *floodCounter) count() int64 {
+	return atomic.LoadInt64(&c.handled)
+}
+
+// wait 发送floodDoneID并等待之前的消息处理完
+func (c *floodCounter) wait(t *testing.T, conn net.Conn) {
+	flood(t, conn, floodDoneID, 1, 0)
+	select {
+	case <-c.done:
+	case r := <-c.closed:
+		t.Fatalf("session closed unexpectedly: %v", r)
+	case <-time.After(2 * time.Second):
+		t.Fatalf("flood not handled, %d messages so far", c.count())
+	}
+}
+
+// floodDoneID单独限制,不占用全局令牌
+func newFloodServer(clock *fakeClock, opts ...Option) *Server {
+	opts = append(opts, withFakeClock(clock), WithMsgLimit(floodDoneID, RateLimit{MsgPerSec: 1}))
+	return NewServer("127.0.0.1:0", opts...)
+}
+
+func TestFloodDrop(t *testing.T) {
+	clock := &fakeClock{t: time.Unix(0, 0)}
+	s := newFloodServer(clock, WithInboundLimit(RateLimit{MsgPerSec: 50}), WithFloodAction(FloodDrop))
+	c := newFloodCounter(s)
+	startTestServer(t, s)
+	conn := dialTestServer(t, s)
+	flood(t, conn, floodMsgID, 1000, 16)
+	c.wait(t, conn)
+	// 时钟不前进时只放行桶容量
+	if n := c.count(); n != 50 {
+		t.Fatalf("handled %d messages, want 50", n)
+	}
+	// 补充1秒的令牌后再放行50个
+	clock.sleep(time.Second)
+	flood(t, conn, floodMsgID, 1000, 16)
+	c.wait(t, conn)
+	if n := c.count(); n != 100 {
+		t.Fatalf("handled %d messages after refill, want 100", n)
+	}
+}
+
+func TestFloodDisconnect(t *testing.T) {
+	clock := &fakeClock{t: time.Unix(0, 0)}
+	s := newFloodServer(clock, WithInboundLimit(RateLimit{MsgPerSec: 50}), WithFloodAction(FloodDisconnect))
+	c := newFloodCounter(s)
+	startTestServer(t, s)
+	conn := dialTestServer(t, s)
+	flood(t, conn, floodMsgID, 1000, 16)
+	select {
+	case r := <-c.closed:
+		if r != base.CloseFlood {
+			t.Fatalf("close reason = %v, want %v", r, base.CloseFlood)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("session not closed")
+	}
+	if n := c.count(); n != 50 {
+		t.Fatalf("handled %d messages, want 50", n)
+	}
+}
+
+func TestFloodDelay(t *testing.T) {
+	clock := &fakeClock{t: time.Unix(0, 0)}
+	s := newFloodServer(clock, WithInboundLimit(RateLimit{MsgPerSec: 200}), WithFloodAction(FloodDelay))
+	c := newFloodCounter(s)
+	startTestServer(t, s)
+	conn := dialTestServer(t, s)
+	flood(t, conn, floodMsgID, 300, 16)
+	c.wait(t, conn)
+	if n := c.count(); n != 300 {
+		t.Fatalf("handled %d messages, want 300", n)
+	}
+	// 超出突发量的100个消息等待500ms
+	if d := clock.totalSlept(); d < 500*time.Millisecond-time.Microsecond || d > 500*time.Millisecond+time.Microsecond {
+		t.Fatalf("slept %v, want 500ms", d)
+	}
+}
+
+func TestFloodBytesAndMsgOverride(t *testing.T) {
+	clock := &fakeClock{t: time.Unix(0, 0)}
+	s := newFloodServer(clock,
+		WithInboundLimit(RateLimit{BytesPerSec: 1024}),
+		WithMsgLimit(floodMsgID+1, RateLimit{MsgPerSec: 10000}),
+		WithFloodAction(FloodDrop))
+	c := newFloodCounter(s)
+	startTestServer(t, s)
+	conn := dialTestServer(t, s)
+	flood(t, conn, floodMsgID, 100, 100)
+	flood(t, conn, floodMsgID+1, 100, 100)
+	c.wait(t, conn)
+	// 全局字节限制放行10个,覆盖的msgID全部放行
+	if n := c.count(); n != 110 {
+		t.Fatalf("handled %d messages, want 110", n)
+	}
+}
+
+func TestMaxConnPerIP(t *testing.T) {
+	s := NewServer("127.0.0.1:0", WithMaxConnPerIP(2))
+	var connected int64
+	s.BindPacketFunc(func(req base.IRequest) bool {
+		if req.GetMsgID() == base.SessionConnect {
+			atomic.AddInt64(&connected, 1)
+		}
+		return true
+	})
+	startTestServer(t, s)
+	for i := 0; i < 4; i++ {
+		dialTestServer(t, s)
+	}
+	time.Sleep(100 * time.Millisecond)
+	if n := atomic.LoadInt64(&connected); n != 2 {
+		t.Fatalf("%d sessions accepted, want 2", n)
+	}
+}
+
+// 服务器关闭的连接读到EOF,未关闭的读超时
+func connClosed(t *testing.T, conn net.Conn, wait time.Duration) bool {
+	_ = conn.SetReadDeadline(time.Now().Add(wait))
+	_, err := conn.Read(make([]byte, 1))
+	if ne, ok := err.(net.Error); ok && ne.Timeout() {
+		return false
+	}
+	if err == nil {
+		t.Fatal("unexpected data from server")
+	}
+	return true
+}
+
+func waitSessions(t *testing.T, s *Server, n int) {
+	for deadline := time.Now().Add(time.Second); len(s.Sessions()) != n; {
+		if time.Now().After(deadline) {
+			t.Fatalf("%d sessions, want %d", len(s.Sessions()), n)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestMaxConnReject(t *testing.T) {
+	s := NewServer("127.0.0.1:0", WithMaxConn(2, false))
+	startTestServer(t, s)
+	c1 := dialTestServer(t, s)
+	dialTestServer(t, s)
+	waitSessions(t, s, 2)
+	if c3 := dialTestServer(t, s); !connClosed(t, c3, time.Second) {
+		t.Fatal("connection over limit not closed")
+	}
+	// 释放名额后可以再次接入
+	_ = c1.Close()
+	waitSessions(t, s, 1)
+	c4 := dialTestServer(t, s)
+	waitSessions(t, s, 2)
+	if connClosed(t, c4, 50*time.Millisecond) {
+		t.Fatal("connection closed after a slot was released")
+	}
+}
+
+func TestMaxConnPause(t *testing.T) {
+	s := NewServer("127.0.0.1:0", WithMaxConn(1, true))
+	startTestServer(t, s)
+	c1 := dialTestServer(t, s)
+	waitSessions(t, s, 1)
+	c2 := dialTestServer(t, s)
+	// 达到上限后不再accept,连接留在backlog中而不是被关闭
+	if connClosed(t, c2, 50*time.Millisecond) {
+		t.Fatal("connection closed while accept paused")
+	}
+	if n := len(s.Sessions()); n != 1 {
+		t.Fatalf("%d sessions while paused, want 1", n)
+	}
+	_ = c1.Close()
+	for deadline := time.Now().Add(time.Second); ; time.Sleep(time.Millisecond) {
+		if sessions := s.Sessions(); len(sessions) == 1 && sessions[0].RemoteAddr == c2.LocalAddr().String() {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("paused connection not accepted after a slot was released")
+		}
+	}
+}
+
+func TestAcceptRate(t *testing.T) {
+	clock := &fakeClock{t: time.Unix(0, 0)}
+	accepted := make(chan time.Duration, 5)
+	s := NewServer("127.0.0.1:0", WithAcceptRate(10, 2), withFakeClock(clock),
+		WithOnAccept(func(conn net.Conn) bool {
+			accepted <- clock.now().Sub(time.Unix(0, 0))
+			return true
+		}))
+	startTestServer(t, s)
+	for i := 0; i < 5; i++ {
+		dialTestServer(t, s)
+	}
+	// 突发2个之后每100ms接入一个
+	want := []time.Duration{0, 0, 100 * time.Millisecond, 200 * time.Millisecond, 300 * time.Millisecond}
+	for i, w := range want {
+		select {
+		case d := <-accepted:
+			if d < w-time.Microsecond || d > w+time.Microsecond {
+				t.Fatalf("connection %d accepted at %v, want %v", i, d, w)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("connection %d not accepted", i)
+		}
+	}
+}
+
+func TestCloseDuringAcceptWait(t *testing.T) {
+	//第一个连接之后需要等待10秒
+	s := NewServer("127.0.0.1:0", WithAcceptRate(0.1, 1))
+	if err := s.Serve(); err != nil {
+		t.Fatal(err)
+	}
+	dialTestServer(t, s)
+	for deadline := time.Now().Add(time.Second); len(s.Sessions()) == 0; time.Sleep(time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatal("connection not accepted")
+		}
+	}
+	s.Close()
+	select {
+	case <-s.Done():
+	case <-time.After(time.Second):
+		t.Fatal("accept loop still waiting for the rate limiter after Close")
+	}
+	if err := s.Err(); err != nil {
+		t.Fatalf("Err = %v after Close", err)
+	}
+	waitRecycled(s)
+}
+
+func TestOnAcceptVeto(t *testing.T) {
+	var calls int64
+	s := NewServer("127.0.0.1:0", WithOnAccept(func(conn net.Conn) bool {
+		//拒绝第一个连接
+		return atomic.AddInt64(&calls, 1) > 1
+	}))
+	startTestServer(t, s)
+	if c1 := dialTestServer(t, s); !connClosed(t, c1, time.Second) {
+		t.Fatal("vetoed connection not closed")
+	}
+	c2 := dialTestServer(t, s)
+	waitSessions(t, s, 1)
+	if connClosed(t, c2, 50*time.Millisecond) {
+		t.Fatal("accepted connection closed")
+	}
+}
+
+func TestServeInvalidAddr(t *testing.T) {
+	s := NewServer("udp://127.0.0.1:0")
+	if err := s.Serve(); err == nil {
+		s.Close()
+		t.Fatal("expected listen error")
+	}
+}
+
+type tempError struct{}
+
+func (tempError) Error() string   { return "too many open files" }
+func (tempError) Timeout() bool   { return false }
+func (tempError) Temporary() bool { return true }
+
+type acceptResult struct {
+	conn net.Conn
+	err  error
+}
+
+// fakeListener 按顺序返回accept结果
+type fakeListener struct {
+	results chan acceptResult
+	closed  chan struct{}
+	once    sync.Once
+}
+
+func (l *fakeListener) Accept() (net.Conn, error) {
+	select {
+	case r := <-l.results:
+		return r.conn, r.err
+	case <-l.closed:
+		return nil, net.ErrClosed
+	}
+}
+
+func (l *fakeListener) Close() error {
+	l.once.Do(func() { close(l.closed) })
+	return nil
+}
+
+func (l *fakeListener) Addr() net.Addr {
+	return &net.TCPAddr{IP: net.IPv4(127, 0, 0, 1)}
+}
+
+func TestAcceptTemporaryErrorBackoff(t *testing.T) {
+	logger := new(recordLogger)
+	ln := &fakeListener{results: make(chan acceptResult), closed: make(chan struct{})}
+	s := NewServer("127.0.0.1:0", WithLogger(logger), WithConnLog(false))
+	s.l = &listener{ln: ln}
+	go s.serve()
+
+	server1, client1 := net.Pipe()
+	defer client1.Close()
+	server2, client2 := net.Pipe()
+	defer client2.Close()
+	// 最后一次accept在前一次退避结束后才会被接收,此时之前的重试日志都已输出
+	for _, r := range []acceptResult{{err: tempError{}}, {err: tempError{}}, {err: tempError{}}, {conn: server1},
+		{err: tempError{}}, {err: tempError{}}, {conn: server2}} {
+		ln.results <- r
+	}
+	s.Close()
+	<-s.Done()
+	if err := s.Err(); err != nil {
+		t.Fatalf("Err() = %v after Close", err)
+	}
+	waitRecycled(s)
+
+	var delays []string
+	for _, line := range logger.snapshot() {
+		if i := strings.Index(line, "retrying in "); i >= 0 {
+			delays = append(delays, line[i+len("retrying in "):])
+		}
+	}
+	// 成功accept后退避时间重置
+	want := []string{"5ms", "10ms", "20ms", "5ms", "10ms"}
+	if strings.Join(delays, ",") != strings.Join(want, ",") {
+		t.Fatalf("retry delays %v, want %v", delays, want)
+	}
+}
+
+func TestListenAndServeClose(t *testing.T) {
+	s := NewServer("127.0.0.1:0")
+	errC := make(chan error, 1)
+	go func() {
+		errC <- s.ListenAndServe()
+	}()
+	for s.Addr() == nil {
+		time.Sleep(time.Millisecond)
+	}
+	conn := dialTestServer(t, s)
+	s.Close()
+	select {
+	case err := <-errC:
+		if err != nil {
+			t.Fatalf("ListenAndServe returned %v after Close", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("ListenAndServe did not return after Close")
+	}
+	select {
+	case <-s.Done():
+	default:
+		t.Fatal("Done not closed")
+	}
+	_ = conn.SetReadDeadline(time.Now().Add(time.Second))
+	if _, err := conn.Read(make([]byte, 1)); err == nil {
+		t.Fatal("session still open after Close")
+	}
+	waitRecycled(s)
+}
+
+// activeSessions 运行采集函数读取在线session数
+func activeSessions() float64 {
+	r := metrics.NewRegistry()
+	metrics.Collect(r)
+	return r.Value(metricSessionsActive)
+}
+
+func TestSessionMetrics(t *testing.T) {
+	r := metrics.NewRegistry()
+	metrics.SetSink(r)
+	defer metrics.SetSink(nil)
+	s := NewServer("127.0.0.1:0")
+	c := newFloodCounter(s)
+	startTestServer(t, s)
+	conn := dialTestServer(t, s)
+	flood(t, conn, floodMsgID, 3, 10)
+	for c.count() < 3 {
+		time.Sleep(time.Millisecond)
+	}
+	if v := activeSessions(); v != 1 {
+		t.Fatalf("%s = %v, want 1", metricSessionsActive, v)
+	}
+	_ = conn.Close()
+	<-c.closed
+	// 关闭回调先于session回收
+	for deadline := time.Now().Add(time.Second); activeSessions() != 0 && time.Now().Before(deadline); {
+		time.Sleep(time.Millisecond)
+	}
+	if v := activeSessions(); v != 0 {
+		t.Fatalf("%s = %v after close, want 0", metricSessionsActive, v)
+	}
+	id := msgIDLabel(floodMsgID)
+	for _, want := range []struct {
+		name   string
+		labels []string
+		value  float64
+	}{
+		{metricAccepts, nil, 1},
+		{metricMessagesIn, []string{"msgid", id}, 3},
+		{metricBytesIn, []string{"msgid", id}, 30},
+		{metricSessionCloses, []string{"reason", base.CloseReadError.String()}, 1},
+	} {
+		if v := r.Value(want.name, want.labels...); v != want.value {
+			t.Errorf("%s%v = %v, want %v", want.name, want.labels, v, want.value)
+		}
+	}
+}
+
+func TestHandlerPanic(t *testing.T) {
+	for _, closeOnPanic := range []bool{false, true} {
+		panics := make(chan uint32, 10)
+		s := NewServer("127.0.0.1:0", WithCloseOnPanic(closeOnPanic),
+			WithPanicHandler(func(req base.IRequest, err interface{}, stack []byte) {
+				if len(stack) == 0 {
+					t.Error("empty panic stack")
+				}
+				panics <- req.GetMsgID()
+			}))
+		s.BindPacketFunc(func(req base.IRequest) bool {
+			if req.GetMsgID() == floodMsgID {
+				panic("bad handler")
+			}
+			return false
+		})
+		c := newFloodCounter(s)
+		startTestServer(t, s)
+		conn := dialTestServer(t, s)
+		flood(t, conn, floodMsgID, 1, 0)
+		flood(t, conn, floodMsgID+1, 1, 0)
+		select {
+		case id := <-panics:
+			if id != floodMsgID {
+				t.Fatalf("panic reported for msgID %d", id)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("panic handler not called")
+		}
+		if !closeOnPanic {
+			for c.count() < 1 {
+				time.Sleep(time.Millisecond)
+			}
+			continue
+		}
+		select {
+		case r := <-c.closed:
+			if r != base.ClosePanic {
+				t.Fatalf("close reason = %v, want %v", r, base.ClosePanic)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("session not closed after panic")
+		}
+	}
+}
+
+// 工作协程中处理的消息panic时与同步处理一样记录日志并关闭连接
+func TestSafeCallAsync(t *testing.T) {
+	logger := new(recordLogger)
+	s := NewServer("127.0.0.1:0", WithCloseOnPanic(true), WithLogger(logger))
+	s.BindPacketFunc(func(req base.IRequest) bool {
+		if req.GetMsgID() == floodMsgID {
+			go s.SafeCall(req, func() { panic("bad async handler") })
+			return true
+		}
+		return false
+	})
+	c := newFloodCounter(s)
+	startTestServer(t, s)
+	conn := dialTestServer(t, s)
+	flood(t, conn, floodMsgID, 1, 0)
+	select {
+	case r := <-c.closed:
+		if r != base.ClosePanic {
+			t.Fatalf("close reason = %v, want %v", r, base.ClosePanic)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("session not closed after async panic")
+	}
+	var found bool
+	for _, line := range logger.snapshot() {
+		if strings.HasPrefix(line, "ERROR handler panic:") && strings.Contains(line, "bad async handler") &&
+			strings.Contains(line, "remote="+conn.LocalAddr().String()) {
+			found = true
+		}
+	}
+	if !found {
+		t.Fatalf("panic not logged through the server logger: %q", logger.snapshot())
+	}
+}
+
+type recordLogger struct {
+	mux   sync.Mutex
+	lines []string
+}
+
+func (l *recordLogger) record(level, format string, args ...interface{}) {
+	l.mux.Lock()
+	l.lines = append(l.lines, level+" "+fmt.Sprintf(format, args...))
+	l.mux.Unlock()
+}
+
+func (l *recordLogger) Debugf(format string, args ...interface{}) { l.record("DEBUG", format, args...) }
+func (l *recordLogger) Infof(format string, args ...interface{})  { l.record("INFO", format, args...) }
+func (l *recordLogger) Warnf(format string, args ...interface{})  { l.record("WARN", format, args...) }
+func (l *recordLogger) Errorf(format string, args ...interface{}) { l.record("ERROR", format, args...) }
+
+func (l *recordLogger) snapshot() []string {
+	l.mux.Lock()
+	defer l.mux.Unlock()
+	return append([]string(nil), l.lines...)
+}
+
+func TestSessionLogger(t *testing.T) {
+	for _, connLog := range []bool{true, false} {
+		logger := new(recordLogger)
+		s := NewServer("127.0.0.1:0", WithLogger(logger), WithConnLog(connLog))
+		c := newFloodCounter(s)
+		startTestServer(t, s)
+		conn := dialTestServer(t, s)
+		remote := conn.LocalAddr().String()
+		// 消息长度超出MaxPacketLen,解包失败
+		_, _ = conn.Write([]byte{0, 0, 0, 1, 0xff, 0xff, 0xff, 0xff})
+		<-c.closed
+		time.Sleep(50 * time.Millisecond)
+		lines := logger.snapshot()
+		if !connLog {
+			if len(lines) != 0 {
+				t.Fatalf("connection log not silenced: %q", lines)
+			}
+			continue
+		}
+		var decodeErr bool
+		for _, line := range lines {
+			if !strings.Contains(line, "session=1 remote="+remote) {
+				t.Errorf("line without session fields: %q", line)
+			}
+			if strings.HasPrefix(line, "WARN") && strings.Contains(line, "decode error") {
+				decodeErr = true
+			}
+		}
+		if !decodeErr {
+			t.Errorf("decode error not logged: %q", lines)
+		}
+	}
+}
+
+func TestRequestContext(t *testing.T) {
+	logger, _ := log.NewLogger(log.WithDisableReportCaller(true))
+	defer logger.Close()
+	tail := log.NewMemoryWriter(10)
+	logger.AddWriter(tail)
+	s := NewServer("127.0.0.1:0")
+	handled := make(chan struct{})
+	s.BindPacketFunc(func(req base.IRequest) bool {
+		if req.GetMsgID() == floodMsgID {
+			logger.InfoCtx(req.Context(), "handled")
+			close(handled)
+		}
+		return false
+	})
+	startTestServer(t, s)
+	conn := dialTestServer(t, s)
+	flood(t, conn, floodMsgID, 1, 10)
+	<-handled
+	id := s.Sessions()[0].ID
+	lines := tail.Lines()
+	want := fmt.Sprintf("handled msgid=%d session_id=%d\n", floodMsgID, id)
+	if len(lines) != 1 || !strings.HasSuffix(lines[0], want) {
+		t.Fatalf("got %q, want suffix %q", lines, want)
+	}
+}
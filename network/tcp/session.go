@@ -2,14 +2,20 @@ package tcp
 
 import (
 	"bytes"
+	"context"
 	"errors"
-	"fmt"
+	"jnet/log"
+	"jnet/network"
 	"jnet/network/base"
 	"net"
 	"sync"
 	"sync/atomic"
+	"time"
 )
 
+// 请求context中消息ID的字段名
+const msgIDField = "msgid"
+
 const (
 	state_null = iota
 	state_run
@@ -25,6 +31,14 @@ type session struct {
 	recvBuffer *bytes.Buffer
 	state      int32
 	property   sync.Map
+	ip         string //准入控制占用的IP标识
+	limiter    *inboundLimiter
+	reason     int32 //base.CloseReason
+	createdAt  time.Time
+	remote     string
+	bytesIn    uint64
+	bytesOut   uint64
+	ctx        context.Context //携带session_id,每个请求在此基础上附加msgid
 }
 
 func newSession(conn net.Conn, s *Server) *session {
@@ -34,7 +48,14 @@ func newSession(conn net.Conn, s *Server) *session {
 	ses.Codec = s.Codec
 	ses.recvBuffer = new(bytes.Buffer)
 	ses.msgChan = make(chan []byte, 1024)
+	ses.limiter = newInboundLimiter(s.SvrOpt)
+	ses.reason = int32(base.CloseNone)
+	ses.createdAt = time.Now()
+	ses.remote = conn.RemoteAddr().String()
+	ses.bytesIn = 0
+	ses.bytesOut = 0
 	ses.SetID(s.GetIncrID())
+	ses.ctx = log.ContextWithSessionID(context.Background(), ses.ID())
 	s.Store(ses.ID(), ses)
 	ses.server = s
 	tc, ok := conn.(*net.TCPConn)
@@ -49,20 +70,32 @@ func newSession(conn net.Conn, s *Server) *session {
 }
 
 func (s *session) Close() {
+	s.CloseWithReason(base.CloseActive)
+}
+
+// CloseWithReason 关闭连接,只记录第一次关闭的原因
+func (s *session) CloseWithReason(reason base.CloseReason) {
+	atomic.CompareAndSwapInt32(&s.reason, int32(base.CloseNone), int32(reason))
 	if atomic.CompareAndSwapInt32(&s.state, state_run, state_stop) {
 		_ = s.conn.Close()
 	}
 }
 
+func (s *session) CloseReason() base.CloseReason {
+	return base.CloseReason(atomic.LoadInt32(&s.reason))
+}
+
 func (s *session) SetState(state int32) {
 	atomic.StoreInt32(&s.state, state)
 }
 
 func (s *session) Start() {
 	s.SetState(state_run)
+	s.debugf("session open")
 	s.server.HandlePacket(&base.Request{
 		Ses: s,
 		Msg: base.NewMsgPackage(base.SessionConnect, nil),
+		Ctx: s.ctx,
 	})
 	go s.StartReader()
 	go s.StartWriter()
@@ -80,29 +113,38 @@ func (s *session) StartReader() {
 	for {
 		n, err := s.conn.Read(packet[:])
 		if err != nil {
+			s.CloseWithReason(base.CloseReadError)
 			break
 		}
+		atomic.AddUint64(&s.bytesIn, uint64(n))
 		s.recvBuffer.Write(packet[:n])
 		err = s.processRead()
+		if err == errFlood {
+			s.CloseWithReason(base.CloseFlood)
+			break
+		}
 		if err != nil {
-			fmt.Println("session ID read err ", err.Error())
+			s.warnf("decode error: %v", err)
+			s.CloseWithReason(base.CloseDecodeError)
 			break
 		}
 	}
 	close(s.msgChan)
 	s.server.recycleSession(s)
-	fmt.Println(s.ID(), "read close")
+	s.debugf("read close: %v", s.CloseReason())
 }
 
 func (s *session) StartWriter() {
 	defer func() {
-		fmt.Println(s.ID(), "write close")
+		s.debugf("write close")
 	}()
 	for {
 		select {
 		case data, ok := <-s.msgChan:
 			if ok {
-				if _, err := s.conn.Write(data); err != nil {
+				n, err := s.conn.Write(data)
+				atomic.AddUint64(&s.bytesOut, uint64(n))
+				if err != nil {
 					return
 				}
 			} else {
@@ -113,6 +155,31 @@ func (s *session) StartWriter() {
 
 }
 
+// 单个连接的日志,每行带上session和远端地址
+func (s *session) debugf(format string, args ...interface{}) {
+	if s.server.connLog {
+		s.server.logger.Debugf("session=%d remote=%s "+format, append([]interface{}{s.ID(), s.remote}, args...)...)
+	}
+}
+
+func (s *session) warnf(format string, args ...interface{}) {
+	if s.server.connLog {
+		s.server.logger.Warnf("session=%d remote=%s "+format, append([]interface{}{s.ID(), s.remote}, args...)...)
+	}
+}
+
+func (s *session) info() network.SessionInfo {
+	return network.SessionInfo{
+		ID:         s.ID(),
+		RemoteAddr: s.remote,
+		CreatedAt:  s.createdAt,
+		Age:        time.Since(s.createdAt).Seconds(),
+		BytesIn:    atomic.LoadUint64(&s.bytesIn),
+		BytesOut:   atomic.LoadUint64(&s.bytesOut),
+		QueueDepth: len(s.msgChan),
+	}
+}
+
 func (s *session) Next(n int) []byte {
 	return s.recvBuffer.Next(n)
 }
@@ -129,8 +196,9 @@ func (s *session) Send(msgID uint32, data []byte) error {
 	}
 	select {
 	case s.msgChan <- rawMsg:
+		countSend(msgID, len(rawMsg))
 	default:
-		s.Close()
+		s.CloseWithReason(base.CloseSendOverflow)
 	}
 	return nil
 }
@@ -149,10 +217,22 @@ func (s *session) processRead() (err error) {
 		if decodeMsg == nil {
 			break
 		}
+		countRecv(decodeMsg.GetMsgID(), decodeMsg.GetDataLen())
+		if s.limiter != nil {
+			ok, er := s.limiter.allow(decodeMsg)
+			if er != nil {
+				err = er
+				break
+			}
+			if !ok {
+				continue
+			}
+		}
 		//handleMsg
 		req := &base.Request{
 			Ses: s,
 			Msg: decodeMsg,
+			Ctx: log.ContextWithFields(s.ctx, log.Fields{msgIDField: decodeMsg.GetMsgID()}),
 		}
 		s.server.HandlePacket(req)
 	}
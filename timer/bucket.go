@@ -4,15 +4,26 @@ import (
 	"container/list"
 	"sync"
 	"sync/atomic"
+	"time"
 	"unsafe"
 )
 
+// Timer状态,每次调度最多执行一次
+const (
+	timerPending int32 = iota // 在时间格中或正在转移到下层时间轮
+	timerFiring               // 已到期,任务正在或已经执行
+	timerStopped              // 已停止,不再执行
+)
+
 type Timer struct {
 	expiration int64
 	task       func()
 	b          unsafe.Pointer //*bucket
 	element    *list.Element
-	canceled   int32
+	state      int32
+	mux        sync.Mutex //保护expiration和状态变化,加锁顺序Timer.mux -> bucket.mux
+	tw         *TimingWheel
+	tag        string
 }
 
 func (t *Timer) getBucket() *bucket {
@@ -23,8 +34,73 @@ func (t *Timer) setBucket(b *bucket) {
 	atomic.StorePointer(&t.b, unsafe.Pointer(b))
 }
 
-func (t *Timer) Stop() {
-	atomic.StoreInt32(&t.canceled, 1)
+func (t *Timer) getExpiration() int64 {
+	t.mux.Lock()
+	defer t.mux.Unlock()
+	return t.expiration
+}
+
+// Stop 停止定时器并立即从时间格中移除,返回true表示阻止了本次执行;
+// 周期任务执行过程中调用时返回false,但之后不再调度
+func (t *Timer) Stop() bool {
+	t.mux.Lock()
+	stopped := t.stop()
+	t.mux.Unlock()
+	if t.tag != "" {
+		t.tw.tags.remove(t)
+	}
+	return stopped
+}
+
+// Tag 创建时指定的标签,见AfterFuncWithTag
+func (t *Timer) Tag() string {
+	return t.tag
+}
+
+// Expiration 本次调度的到期时间
+func (t *Timer) Expiration() time.Time {
+	return nsToTime(t.getExpiration())
+}
+
+func (t *Timer) stop() bool {
+	switch t.state {
+	case timerPending:
+		t.state = timerStopped
+		// 正在转移的定时器不在时间格中,重新插入时丢弃
+		if b := t.getBucket(); b != nil {
+			b.Remove(t)
+		}
+		return true
+	case timerFiring:
+		t.state = timerStopped
+	}
+	return false
+}
+
+// Reset 停止后在d之后重新执行,返回定时器在调用前是否处于等待状态
+func (t *Timer) Reset(d time.Duration) bool {
+	t.mux.Lock()
+	active := t.stop()
+	t.expiration = timeToNs(t.tw.opt.clock.Now().Add(d))
+	t.state = timerPending
+	t.mux.Unlock()
+	if t.tag != "" {
+		t.tw.tags.add(t)
+	}
+	t.tw.addOrRun(t)
+	return active
+}
+
+// rearm 周期任务执行后重新调度,执行过程中被停止时返回false
+func (t *Timer) rearm(expiration int64) bool {
+	t.mux.Lock()
+	defer t.mux.Unlock()
+	if t.state != timerFiring {
+		return false
+	}
+	t.expiration = expiration
+	t.state = timerPending
+	return true
 }
 
 //相同过期时间的任务
@@ -52,11 +128,11 @@ func (b *bucket) Expiration() int64 {
 	return atomic.LoadInt64(&b.expiration)
 }
 
-/*func (b *bucket) Remove(t *Timer) bool {
+func (b *bucket) Remove(t *Timer) bool {
 	b.mux.Lock()
 	defer b.mux.Unlock()
 	return b.remove(t)
-}*/
+}
 
 func (b *bucket) remove(t *Timer) bool {
 	if t.getBucket() != b {
@@ -68,6 +144,25 @@ func (b *bucket) remove(t *Timer) bool {
 	return true
 }
 
+func (b *bucket) Len() int {
+	b.mux.Lock()
+	defer b.mux.Unlock()
+	return b.timers.Len()
+}
+
+// minExpiration 时间格中最早的到期时间,定时器在时间格中时expiration不会变化
+func (b *bucket) minExpiration() (int64, bool) {
+	b.mux.Lock()
+	defer b.mux.Unlock()
+	min, ok := int64(0), false
+	for e := b.timers.Front(); e != nil; e = e.Next() {
+		if exp := e.Value.(*Timer).expiration; !ok || exp < min {
+			min, ok = exp, true
+		}
+	}
+	return min, ok
+}
+
 func (b *bucket) SetExpiration(expiration int64) bool {
 	return atomic.SwapInt64(&b.expiration, expiration) != expiration
 }
@@ -0,0 +1,133 @@
+package timer
+
+import (
+	"sync"
+	"time"
+)
+
+// Clock 时间轮获取当前时间和等待的方式,测试中用FakeClock控制时间
+type Clock interface {
+	Now() time.Time
+	//到达deadline时触发,使用绝对时间避免读取时间和创建timer之间时钟被调整
+	NewTimerAt(deadline time.Time) ClockTimer
+}
+
+type ClockTimer interface {
+	C() <-chan time.Time
+	Stop() bool
+}
+
+// RealClock 系统时间,默认
+var RealClock Clock = realClock{}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time {
+	return time.Now()
+}
+
+func (realClock) NewTimerAt(deadline time.Time) ClockTimer {
+	return realTimer{time.NewTimer(time.Until(deadline))}
+}
+
+type realTimer struct {
+	*time.Timer
+}
+
+func (t realTimer) C() <-chan time.Time {
+	return t.Timer.C
+}
+
+// FakeClock 手动推进的时钟,只有Advance或Set时时间才会变化
+type FakeClock struct {
+	mux    sync.Mutex
+	cond   *sync.Cond
+	now    time.Time
+	timers map[*fakeTimer]struct{}
+}
+
+type fakeTimer struct {
+	c        chan time.Time
+	deadline time.Time
+	clock    *FakeClock
+}
+
+func NewFakeClock(now time.Time) *FakeClock {
+	c := &FakeClock{
+		now:    now,
+		timers: make(map[*fakeTimer]struct{}),
+	}
+	c.cond = sync.NewCond(&c.mux)
+	return c
+}
+
+func (c *FakeClock) Now() time.Time {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	return c.now
+}
+
+func (c *FakeClock) NewTimerAt(deadline time.Time) ClockTimer {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	t := &fakeTimer{
+		c:        make(chan time.Time, 1),
+		deadline: deadline,
+		clock:    c,
+	}
+	if !deadline.After(c.now) {
+		t.c <- c.now
+		return t
+	}
+	c.timers[t] = struct{}{}
+	c.cond.Broadcast()
+	return t
+}
+
+// Advance 时间前进d,触发到期的timer
+func (c *FakeClock) Advance(d time.Duration) {
+	c.Set(c.Now().Add(d))
+}
+
+// Set 设置当前时间,早于当前时间时不触发任何timer
+func (c *FakeClock) Set(now time.Time) {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	c.now = now
+	for t := range c.timers {
+		if !t.deadline.After(now) {
+			t.c <- now
+			delete(c.timers, t)
+		}
+	}
+	c.cond.Broadcast()
+}
+
+// Waiters 正在等待的timer数量
+func (c *FakeClock) Waiters() int {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	return len(c.timers)
+}
+
+// BlockUntil 阻塞直到有n个timer在等待,用于确认时间轮已进入休眠
+func (c *FakeClock) BlockUntil(n int) {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	for len(c.timers) != n {
+		c.cond.Wait()
+	}
+}
+
+func (t *fakeTimer) C() <-chan time.Time {
+	return t.c
+}
+
+func (t *fakeTimer) Stop() bool {
+	t.clock.mux.Lock()
+	defer t.clock.mux.Unlock()
+	_, ok := t.clock.timers[t]
+	delete(t.clock.timers, t)
+	t.clock.cond.Broadcast()
+	return ok
+}
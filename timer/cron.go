@@ -0,0 +1,266 @@
+package timer
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CronScheduler 按cron表达式计算下次执行时间,配合ScheduleFunc使用
+//
+//	秒(可选) 分 时 日 月 周
+//	"0 5 * * *"          每天05:00
+//	"0 0 * * MON"        每周一00:00
+//	"30 0 */2 * * *"     每两小时的00分30秒
+//	"CRON_TZ=Asia/Shanghai 0 5 * * *"
+//
+// 支持 * ? , - / 、月份和星期的英文缩写,以及@yearly @monthly @weekly @daily @hourly;
+// 日和周都不为*时满足其一即可,与标准cron一致
+type CronScheduler struct {
+	second, minute, hour, dom, month, dow uint64
+	loc                                   *time.Location
+}
+
+type cronField struct {
+	name     string
+	min, max int
+	names    map[string]int
+}
+
+var (
+	cronSecond = cronField{name: "second", min: 0, max: 59}
+	cronMinute = cronField{name: "minute", min: 0, max: 59}
+	cronHour   = cronField{name: "hour", min: 0, max: 23}
+	cronDom    = cronField{name: "day of month", min: 1, max: 31}
+	cronMonth  = cronField{name: "month", min: 1, max: 12, names: map[string]int{
+		"jan": 1, "feb": 2, "mar": 3, "apr": 4, "may": 5, "jun": 6,
+		"jul": 7, "aug": 8, "sep": 9, "oct": 10, "nov": 11, "dec": 12,
+	}}
+	//7也表示周日
+	cronDow = cronField{name: "day of week", min: 0, max: 7, names: map[string]int{
+		"sun": 0, "mon": 1, "tue": 2, "wed": 3, "thu": 4, "fri": 5, "sat": 6,
+	}}
+
+	cronDescriptors = map[string]string{
+		"@yearly":   "0 0 0 1 1 *",
+		"@annually": "0 0 0 1 1 *",
+		"@monthly":  "0 0 0 1 * *",
+		"@weekly":   "0 0 0 * * 0",
+		"@daily":    "0 0 0 * * *",
+		"@midnight": "0 0 0 * * *",
+		"@hourly":   "0 0 * * * *",
+	}
+)
+
+// 日或周为*时的标记位,用于判断日和周的组合方式
+const cronStar = 1 << 63
+
+// ParseCron 解析cron表达式,未指定CRON_TZ时使用服务器本地时区
+func ParseCron(spec string) (*CronScheduler, error) {
+	return ParseCronInLocation(spec, time.Local)
+}
+
+// ParseCronInLocation 同ParseCron,未指定CRON_TZ时使用loc
+func ParseCronInLocation(spec string, loc *time.Location) (*CronScheduler, error) {
+	spec = strings.TrimSpace(spec)
+	if strings.HasPrefix(spec, "CRON_TZ=") || strings.HasPrefix(spec, "TZ=") {
+		i := strings.IndexAny(spec, " \t")
+		if i < 0 {
+			return nil, fmt.Errorf("cron: missing fields in %q", spec)
+		}
+		var err error
+		tz := spec[strings.Index(spec, "=")+1 : i]
+		if loc, err = time.LoadLocation(tz); err != nil {
+			return nil, fmt.Errorf("cron: invalid time zone %q: %v", tz, err)
+		}
+		spec = strings.TrimSpace(spec[i:])
+	}
+	if strings.HasPrefix(spec, "@") {
+		d, ok := cronDescriptors[strings.ToLower(spec)]
+		if !ok {
+			return nil, fmt.Errorf("cron: unknown descriptor %q", spec)
+		}
+		spec = d
+	}
+
+	fields := strings.Fields(spec)
+	switch len(fields) {
+	case 5:
+		fields = append([]string{"0"}, fields...)
+	case 6:
+	default:
+		return nil, fmt.Errorf("cron: expected 5 or 6 fields, got %d in %q", len(fields), spec)
+	}
+	s := &CronScheduler{loc: loc}
+	var err error
+	for i, p := range []struct {
+		bits  *uint64
+		field cronField
+	}{
+		{&s.second, cronSecond},
+		{&s.minute, cronMinute},
+		{&s.hour, cronHour},
+		{&s.dom, cronDom},
+		{&s.month, cronMonth},
+		{&s.dow, cronDow},
+	} {
+		if *p.bits, err = p.field.parse(fields[i]); err != nil {
+			return nil, err
+		}
+	}
+	if s.dow&(1<<7) != 0 {
+		s.dow |= 1
+	}
+	return s, nil
+}
+
+// MustParseCron 解析失败时panic,用于固定的表达式
+func MustParseCron(spec string) *CronScheduler {
+	s, err := ParseCron(spec)
+	if err != nil {
+		panic(err)
+	}
+	return s
+}
+
+func (f cronField) parse(expr string) (uint64, error) {
+	var bits uint64
+	for _, item := range strings.Split(expr, ",") {
+		b, err := f.parseItem(item)
+		if err != nil {
+			return 0, err
+		}
+		bits |= b
+	}
+	return bits, nil
+}
+
+// parseItem 解析 * ? n a-b 及其后的/step
+func (f cronField) parseItem(item string) (uint64, error) {
+	rangePart, step := item, 1
+	if i := strings.Index(item, "/"); i >= 0 {
+		n, err := strconv.Atoi(item[i+1:])
+		if err != nil || n <= 0 {
+			return 0, fmt.Errorf("cron: invalid step %q in %s field", item, f.name)
+		}
+		rangePart, step = item[:i], n
+	}
+
+	var (
+		lo, hi int
+		extra  uint64
+		err    error
+	)
+	switch {
+	case rangePart == "*" || rangePart == "?":
+		lo, hi = f.min, f.max
+		if step == 1 {
+			extra = cronStar
+		}
+	case strings.Contains(rangePart, "-"):
+		i := strings.Index(rangePart, "-")
+		if lo, err = f.value(rangePart[:i]); err != nil {
+			return 0, err
+		}
+		if hi, err = f.value(rangePart[i+1:]); err != nil {
+			return 0, err
+		}
+		if lo > hi {
+			return 0, fmt.Errorf("cron: invalid range %q in %s field", rangePart, f.name)
+		}
+	default:
+		if lo, err = f.value(rangePart); err != nil {
+			return 0, err
+		}
+		hi = lo
+		//"5/15"表示从5开始到最大值
+		if step > 1 {
+			hi = f.max
+		}
+	}
+
+	var bits uint64
+	for v := lo; v <= hi; v += step {
+		bits |= 1 << uint(v)
+	}
+	return bits | extra, nil
+}
+
+func (f cronField) value(s string) (int, error) {
+	if v, ok := f.names[strings.ToLower(s)]; ok {
+		return v, nil
+	}
+	v, err := strconv.Atoi(s)
+	if err != nil || v < f.min || v > f.max {
+		return 0, fmt.Errorf("cron: invalid value %q in %s field, want %d-%d", s, f.name, f.min, f.max)
+	}
+	return v, nil
+}
+
+// Location 计算所用的时区
+func (s *CronScheduler) Location() *time.Location {
+	return s.loc
+}
+
+// Next 返回prev之后(不含)第一个满足表达式的时间,5年内没有则返回零值;
+// 夏令时切换时不存在的时刻被跳过,重复的时刻执行两次
+func (s *CronScheduler) Next(prev time.Time) time.Time {
+	t := prev.In(s.loc)
+	t = t.Add(time.Second - time.Duration(t.Nanosecond()))
+	yearLimit := t.Year() + 5
+
+	//由高到低逐个字段匹配,进位后从头检查
+WRAP:
+	if t.Year() > yearLimit {
+		return time.Time{}
+	}
+	for s.month&(1<<uint(t.Month())) == 0 {
+		t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, s.loc)
+		if t.Month() == time.January {
+			goto WRAP
+		}
+	}
+	for !s.dayMatches(t) {
+		t = forward(t, time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, s.loc))
+		if t.Day() == 1 {
+			goto WRAP
+		}
+	}
+	for s.hour&(1<<uint(t.Hour())) == 0 {
+		t = forward(t, time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, s.loc))
+		if t.Hour() == 0 {
+			goto WRAP
+		}
+	}
+	for s.minute&(1<<uint(t.Minute())) == 0 {
+		t = t.Truncate(time.Minute).Add(time.Minute)
+		if t.Minute() == 0 {
+			goto WRAP
+		}
+	}
+	for s.second&(1<<uint(t.Second())) == 0 {
+		t = t.Truncate(time.Second).Add(time.Second)
+		if t.Second() == 0 {
+			goto WRAP
+		}
+	}
+	return t
+}
+
+// forward 夏令时开始时time.Date可能回到切换前,此时按绝对时间前进一小时
+func forward(t, next time.Time) time.Time {
+	if !next.After(t) {
+		return t.Add(time.Hour)
+	}
+	return next
+}
+
+func (s *CronScheduler) dayMatches(t time.Time) bool {
+	dom := s.dom&(1<<uint(t.Day())) != 0
+	dow := s.dow&(1<<uint(t.Weekday())) != 0
+	if s.dom&cronStar != 0 || s.dow&cronStar != 0 {
+		return dom && dow
+	}
+	return dom || dow
+}
@@ -0,0 +1,89 @@
+package timer
+
+import (
+	"testing"
+	"time"
+)
+
+var _ Scheduler = (*CronScheduler)(nil)
+
+func TestCronNext(t *testing.T) {
+	shanghai, err := time.LoadLocation("Asia/Shanghai")
+	if err != nil {
+		t.Skip(err)
+	}
+	from := time.Date(2021, 3, 4, 10, 30, 15, 500, shanghai) //周四
+	for _, c := range []struct {
+		spec string
+		want []string
+	}{
+		{"0 5 * * *", []string{"2021-03-05 05:00:00", "2021-03-06 05:00:00"}},
+		{"0 0 * * MON", []string{"2021-03-08 00:00:00", "2021-03-15 00:00:00"}},
+		{"30 0 */4 * * *", []string{"2021-03-04 12:00:30", "2021-03-04 16:00:30"}},
+		{"0 0 1 jan,Jul *", []string{"2021-07-01 00:00:00", "2022-01-01 00:00:00"}},
+		{"0 12 29 2 *", []string{"2024-02-29 12:00:00", "2028-02-29 12:00:00"}},
+		{"0 0 13 * 5", []string{"2021-03-05 00:00:00", "2021-03-12 00:00:00", "2021-03-13 00:00:00"}},
+		{"0 9 * * 1-5/2", []string{"2021-03-05 09:00:00", "2021-03-08 09:00:00"}},
+		{"0 0 * * 7", []string{"2021-03-07 00:00:00"}},
+		{"45 * * * * ?", []string{"2021-03-04 10:30:45", "2021-03-04 10:31:45"}},
+		{"@weekly", []string{"2021-03-07 00:00:00"}},
+		{"CRON_TZ=UTC 0 5 * * *", []string{"2021-03-04 13:00:00"}},
+	} {
+		s, err := ParseCronInLocation(c.spec, shanghai)
+		if err != nil {
+			t.Fatalf("%q: %v", c.spec, err)
+		}
+		// 以上一次的结果作为下一次的起点,模拟时钟推进
+		now := from
+		for _, w := range c.want {
+			now = s.Next(now)
+			if got := now.In(shanghai).Format("2006-01-02 15:04:05"); got != w {
+				t.Errorf("%q: next = %s, want %s", c.spec, got, w)
+				break
+			}
+		}
+	}
+}
+
+func TestCronDST(t *testing.T) {
+	ny, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skip(err)
+	}
+	s := MustParseCron("CRON_TZ=America/New_York 30 2 * * *")
+	// 2021-03-14 02:30不存在,跳过
+	now := time.Date(2021, 3, 13, 12, 0, 0, 0, ny)
+	for _, w := range []string{"2021-03-15 02:30 EDT", "2021-03-16 02:30 EDT"} {
+		now = s.Next(now)
+		if got := now.Format("2006-01-02 15:04 MST"); got != w {
+			t.Fatalf("next = %s, want %s", got, w)
+		}
+	}
+	// 2021-11-07 01:30出现两次
+	s = MustParseCron("CRON_TZ=America/New_York 30 1 * * *")
+	now = time.Date(2021, 11, 6, 12, 0, 0, 0, ny)
+	for _, w := range []string{"2021-11-07 01:30 EDT", "2021-11-07 01:30 EST", "2021-11-08 01:30 EST"} {
+		now = s.Next(now)
+		if got := now.Format("2006-01-02 15:04 MST"); got != w {
+			t.Fatalf("next = %s, want %s", got, w)
+		}
+	}
+	if s.Location().String() != "America/New_York" {
+		t.Fatalf("location %v", s.Location())
+	}
+}
+
+func TestParseCronErrors(t *testing.T) {
+	for _, spec := range []string{
+		"", "* * * *", "* * * * * * *", "60 * * * * *", "* 24 * * *", "* * 0 * *",
+		"* * * 13 *", "* * * * 8", "* * * foo *", "5-1 * * * *", "*/0 * * * *",
+		"@every 5s", "CRON_TZ=Mars/Base 0 5 * * *",
+	} {
+		if _, err := ParseCron(spec); err == nil {
+			t.Errorf("ParseCron(%q) succeeded", spec)
+		}
+	}
+	if !(&CronScheduler{loc: time.UTC}).Next(time.Now()).IsZero() {
+		t.Error("empty schedule should never fire")
+	}
+}
@@ -2,6 +2,7 @@ package timer
 
 import (
 	"container/heap"
+	"runtime"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -81,6 +82,10 @@ type DelayQueue struct {
 	C          chan interface{}
 	exitChan   chan struct{}
 	sleeping   int32
+	clock      Clock
+
+	waitMode      WaitMode
+	spinThreshold time.Duration
 }
 
 func NewDelayQueue(size int) *DelayQueue {
@@ -89,6 +94,7 @@ func NewDelayQueue(size int) *DelayQueue {
 		wakeupChan: make(chan struct{}),
 		C:          make(chan interface{}),
 		exitChan:   make(chan struct{}),
+		clock:      RealClock,
 	}
 }
 func (d *DelayQueue) Offer(elem interface{}, expiration int64) {
@@ -106,7 +112,7 @@ func (d *DelayQueue) Offer(elem interface{}, expiration int64) {
 
 func (d *DelayQueue) Poll() {
 	for {
-		now := timeToMs(time.Now().UTC())
+		now := timeToNs(d.clock.Now())
 		d.mux.Lock()
 		item, recentComing := d.pq.PeekAndShift(now)
 		if item == nil {
@@ -123,19 +129,10 @@ func (d *DelayQueue) Poll() {
 					goto exit
 				}
 			} else if recentComing > 0 { // 最近至少一个任务存在
-				select {
-				case <-d.wakeupChan: // 添加了一个比当前最近任务更早的任务
-					continue
-				case <-time.After(time.Duration(recentComing) * (time.Millisecond)): // 当前任务是最早过期的任务
-					//不再需要从唤醒管道中获取 重置休眠状态
-					if atomic.SwapInt32(&d.sleeping, 0) == 0 {
-						//解除阻塞
-						<-d.wakeupChan
-					}
-					continue
-				case <-d.exitChan:
+				if !d.waitUntil(now + recentComing) {
 					goto exit
 				}
+				continue
 			}
 		}
 		select {
@@ -147,7 +144,74 @@ func (d *DelayQueue) Poll() {
 exit:
 	atomic.StoreInt32(&d.sleeping, 0)
 }
+
+// waitUntil 等待到deadline或被更早的任务唤醒,返回false表示退出
+func (d *DelayQueue) waitUntil(deadline int64) bool {
+	sleepUntil := deadline
+	switch d.waitMode {
+	case WaitSpin:
+		sleepUntil = 0
+	case WaitHybrid:
+		sleepUntil = deadline - int64(d.spinThreshold)
+	}
+	if sleepUntil > timeToNs(d.clock.Now()) {
+		timer := d.clock.NewTimerAt(nsToTime(sleepUntil))
+		select {
+		case <-d.wakeupChan: // 添加了一个比当前最近任务更早的任务
+			timer.Stop()
+			return true
+		case <-timer.C():
+		case <-d.exitChan:
+			timer.Stop()
+			return false
+		}
+	}
+	//休眠模式不自旋,由Poll重新检查,时钟回拨时继续休眠
+	if d.waitMode == WaitSleep {
+		d.wake()
+		return true
+	}
+	// 自旋直到到期,期间响应唤醒和退出
+	for now := timeToNs(d.clock.Now()); now < deadline; now = timeToNs(d.clock.Now()) {
+		//时钟回拨后距到期超过自旋阈值,回到Poll重新休眠
+		if d.waitMode == WaitHybrid && deadline-now > int64(d.spinThreshold) {
+			d.wake()
+			return true
+		}
+		select {
+		case <-d.wakeupChan:
+			return true
+		case <-d.exitChan:
+			return false
+		default:
+			runtime.Gosched()
+		}
+	}
+	d.wake()
+	return true
+}
+
+// peek 最早到期的时间
+func (d *DelayQueue) peek() (int64, bool) {
+	d.mux.Lock()
+	defer d.mux.Unlock()
+	if d.pq.Len() == 0 {
+		return 0, false
+	}
+	return d.pq[0].Priority, true
+}
+
+// wake 不再需要从唤醒管道中获取 重置休眠状态
+func (d *DelayQueue) wake() {
+	if atomic.SwapInt32(&d.sleeping, 0) == 0 {
+		//解除阻塞
+		<-d.wakeupChan
+	}
+}
+
 func (d *DelayQueue) Len() int64 {
+	d.mux.Lock()
+	defer d.mux.Unlock()
 	return int64(d.pq.Len())
 }
 func (d *DelayQueue) Exit() {
@@ -0,0 +1,112 @@
+package timer
+
+import (
+	"jnet/base/queue"
+	"jnet/log"
+	"jnet/metrics"
+	"runtime/debug"
+	"sync"
+)
+
+const (
+	metricLag    = "jnet_timer_lag_seconds" //任务开始执行时间与到期时间之差
+	metricPanics = "jnet_timer_panics_total"
+)
+
+// Executor 执行到期的任务
+type Executor interface {
+	Execute(task func())
+}
+
+type ExecutorFunc func(task func())
+
+func (f ExecutorFunc) Execute(task func()) {
+	f(task)
+}
+
+// InlineExecutor 在时间轮的consume协程中直接执行,任务耗时会推迟其他定时器,默认
+var InlineExecutor Executor = ExecutorFunc(func(task func()) {
+	task()
+})
+
+// QueueExecutor 投递到EventQueue,在其所在协程中执行
+func QueueExecutor(q queue.EventQueue) Executor {
+	return ExecutorFunc(func(task func()) {
+		q.Post(task, "timer")
+	})
+}
+
+// PoolExecutor 固定数量的工作协程,队列满时阻塞consume协程;
+// 周期任务在上次执行结束前可能再次执行
+type PoolExecutor struct {
+	tasks  chan func()
+	wg     sync.WaitGroup
+	mux    sync.RWMutex
+	closed bool
+}
+
+func NewPoolExecutor(workers, queueSize int) *PoolExecutor {
+	if workers <= 0 {
+		workers = 1
+	}
+	p := &PoolExecutor{
+		tasks: make(chan func(), queueSize),
+	}
+	p.wg.Add(workers)
+	for i := 0; i < workers; i++ {
+		go func() {
+			defer p.wg.Done()
+			for task := range p.tasks {
+				task()
+			}
+		}()
+	}
+	return p
+}
+
+// Execute Stop之后在调用者协程中直接执行
+func (p *PoolExecutor) Execute(task func()) {
+	p.mux.RLock()
+	if p.closed {
+		p.mux.RUnlock()
+		task()
+		return
+	}
+	p.tasks <- task
+	p.mux.RUnlock()
+}
+
+// Stop 执行完队列中的任务后返回
+func (p *PoolExecutor) Stop() {
+	p.mux.Lock()
+	if !p.closed {
+		p.closed = true
+		close(p.tasks)
+	}
+	p.mux.Unlock()
+	p.wg.Wait()
+}
+
+// PanicHandler 任务panic时调用
+type PanicHandler func(err interface{}, stack []byte)
+
+// LogPanic 通过jnet/log输出,默认的PanicHandler
+func LogPanic(err interface{}, stack []byte) {
+	log.Errorf("timer task panic: %v\n%s", err, stack)
+}
+
+// execute 交给Executor执行,记录执行延迟并恢复panic
+func (tw *TimingWheel) execute(t *Timer, expiration int64) {
+	tw.opt.executor.Execute(func() {
+		if metrics.Enabled() {
+			metrics.Observe(metricLag, tw.opt.clock.Now().Sub(nsToTime(expiration)).Seconds())
+		}
+		defer func() {
+			if err := recover(); err != nil {
+				metrics.AddCounter(metricPanics, 1)
+				tw.opt.panicHandler(err, debug.Stack())
+			}
+		}()
+		t.task()
+	})
+}
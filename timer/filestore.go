@@ -0,0 +1,162 @@
+package timer
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"os"
+	"sync"
+)
+
+// 删除的记录超过该数量且多于有效记录时重写文件
+const compactThreshold = 1024
+
+type storeOp struct {
+	Op     string       `json:"op"` //add del
+	Record *TimerRecord `json:"record,omitempty"`
+	ID     uint64       `json:"id,omitempty"`
+}
+
+// FileStore 以追加日志保存定时器,每行一条json格式的add或del操作;
+// 只写入系统缓存不fsync,进程崩溃不丢数据,系统宕机可能丢失最后的操作
+type FileStore struct {
+	path    string
+	mux     sync.Mutex
+	f       *os.File
+	records map[uint64]*TimerRecord
+	dead    int
+	logger  Logger
+}
+
+// NewFileStore logger输出打开时跳过的损坏记录
+func NewFileStore(path string, logger Logger) (*FileStore, error) {
+	s := &FileStore{
+		path:    path,
+		records: make(map[uint64]*TimerRecord),
+		logger:  logger,
+	}
+	if err := s.replay(); err != nil {
+		return nil, err
+	}
+	if err := s.compact(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// replay 读取已有的日志,崩溃时写了一半的行跳过
+func (s *FileStore) replay() error {
+	f, err := os.Open(s.path)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		var op storeOp
+		if err := json.Unmarshal(scanner.Bytes(), &op); err != nil {
+			s.logger.Warnf("timer: skip invalid record at %s:%d: %v", s.path, line, err)
+			continue
+		}
+		switch {
+		case op.Op == "add" && op.Record != nil:
+			s.records[op.Record.ID] = op.Record
+		case op.Op == "del":
+			delete(s.records, op.ID)
+		}
+	}
+	return scanner.Err()
+}
+
+// compact 只保留有效记录,写入临时文件后替换
+func (s *FileStore) compact() error {
+	var buf bytes.Buffer
+	for _, r := range s.records {
+		b, err := json.Marshal(storeOp{Op: "add", Record: r})
+		if err != nil {
+			return err
+		}
+		buf.Write(b)
+		buf.WriteByte('\n')
+	}
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, buf.Bytes(), 0644); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, s.path); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(s.path, os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	if s.f != nil {
+		s.f.Close()
+	}
+	s.f = f
+	s.dead = 0
+	return nil
+}
+
+func (s *FileStore) append(op storeOp) error {
+	if s.f == nil {
+		return errors.New("timer: file store closed")
+	}
+	b, err := json.Marshal(op)
+	if err != nil {
+		return err
+	}
+	_, err = s.f.Write(append(b, '\n'))
+	return err
+}
+
+func (s *FileStore) Save(r *TimerRecord) error {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	if err := s.append(storeOp{Op: "add", Record: r}); err != nil {
+		return err
+	}
+	s.records[r.ID] = r
+	return nil
+}
+
+func (s *FileStore) Delete(id uint64) error {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	if _, ok := s.records[id]; !ok {
+		return nil
+	}
+	if err := s.append(storeOp{Op: "del", ID: id}); err != nil {
+		return err
+	}
+	delete(s.records, id)
+	s.dead++
+	if s.dead > compactThreshold && s.dead > len(s.records) {
+		return s.compact()
+	}
+	return nil
+}
+
+func (s *FileStore) Load() ([]*TimerRecord, error) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	records := make([]*TimerRecord, 0, len(s.records))
+	for _, r := range s.records {
+		records = append(records, r)
+	}
+	return records, nil
+}
+
+func (s *FileStore) Close() error {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	if s.f == nil {
+		return nil
+	}
+	err := s.f.Close()
+	s.f = nil
+	return err
+}
@@ -0,0 +1,247 @@
+package timer
+
+import (
+	"jnet/base/queue"
+	"sync"
+	"time"
+)
+
+const defaultMaxCatchUp = 5
+
+// FrameStats 帧循环的执行统计,耗时按时间轮的Clock计算
+type FrameStats struct {
+	Frame    uint64        `json:"frame"` //下一帧的编号
+	Executed uint64        `json:"executed"`
+	Skipped  uint64        `json:"skipped"`
+	Overruns uint64        `json:"overruns"` //执行时间超过帧间隔的帧数
+	Last     time.Duration `json:"last"`
+	Avg      time.Duration `json:"avg"`
+	Max      time.Duration `json:"max"`
+}
+
+type FrameOption func(o *frameOptions)
+
+type frameOptions struct {
+	maxCatchUp int
+	onSkip     func(skipped uint64)
+}
+
+// WithMaxCatchUp 落后时一次最多追赶的帧数,更早的帧跳过,默认5
+func WithMaxCatchUp(n int) FrameOption {
+	return func(o *frameOptions) {
+		if n > 0 {
+			o.maxCatchUp = n
+		}
+	}
+}
+
+// WithSkipHandler 跳过帧时在EventQueue中调用
+func WithSkipHandler(f func(skipped uint64)) FrameOption {
+	return func(o *frameOptions) {
+		o.onSkip = f
+	}
+}
+
+// FrameLoop 固定帧率的更新循环,回调在指定的EventQueue中执行;
+// 第n帧在 起始时间+(n+1)*interval 执行,不受回调耗时影响,帧号连续且不重复,跳过的帧也占用帧号
+type FrameLoop struct {
+	tw       *TimingWheel
+	q        queue.EventQueue
+	interval time.Duration
+	f        func(frame uint64)
+	opt      frameOptions
+
+	mux     sync.Mutex
+	base    time.Time //第0帧的执行时间,Resume后重新计算
+	frame   uint64
+	gen     uint64 //Pause和Resume后之前投递的执行作废
+	timer   *Timer
+	running bool
+	paused  bool
+	stats   FrameStats
+	total   time.Duration
+}
+
+func NewFrameLoop(tw *TimingWheel, q queue.EventQueue, interval time.Duration, f func(frame uint64), opts ...FrameOption) *FrameLoop {
+	l := &FrameLoop{
+		tw:       tw,
+		q:        q,
+		interval: interval,
+		f:        f,
+		opt:      frameOptions{maxCatchUp: defaultMaxCatchUp},
+	}
+	for _, opt := range opts {
+		opt(&l.opt)
+	}
+	return l
+}
+
+// Start 一个帧间隔后执行第0帧
+func (l *FrameLoop) Start() {
+	l.mux.Lock()
+	defer l.mux.Unlock()
+	if l.running {
+		return
+	}
+	l.running = true
+	l.rebase()
+}
+
+// Stop 停止执行,之后可以重新Start,帧号继续
+func (l *FrameLoop) Stop() {
+	l.mux.Lock()
+	defer l.mux.Unlock()
+	l.running = false
+	l.cancel()
+}
+
+// Pause 暂停期间不执行也不计为跳过
+func (l *FrameLoop) Pause() {
+	l.mux.Lock()
+	defer l.mux.Unlock()
+	if !l.running || l.paused {
+		return
+	}
+	l.paused = true
+	l.cancel()
+}
+
+// Resume 一个帧间隔后继续执行,帧号接着暂停前
+func (l *FrameLoop) Resume() {
+	l.mux.Lock()
+	defer l.mux.Unlock()
+	if !l.running || !l.paused {
+		return
+	}
+	l.paused = false
+	l.rebase()
+}
+
+func (l *FrameLoop) Paused() bool {
+	l.mux.Lock()
+	defer l.mux.Unlock()
+	return l.paused
+}
+
+// Frame 下一帧的编号
+func (l *FrameLoop) Frame() uint64 {
+	l.mux.Lock()
+	defer l.mux.Unlock()
+	return l.frame
+}
+
+func (l *FrameLoop) Stats() FrameStats {
+	l.mux.Lock()
+	defer l.mux.Unlock()
+	s := l.stats
+	s.Frame = l.frame
+	if s.Executed > 0 {
+		s.Avg = l.total / time.Duration(s.Executed)
+	}
+	return s
+}
+
+func (l *FrameLoop) now() time.Time {
+	return l.tw.opt.clock.Now()
+}
+
+// rebase 以当前帧号重新计算起始时间,下一帧在一个间隔后执行
+func (l *FrameLoop) rebase() {
+	l.base = l.now().Add(l.interval - time.Duration(l.frame)*l.interval)
+	l.gen++
+	l.schedule()
+}
+
+func (l *FrameLoop) cancel() {
+	l.gen++
+	if l.timer != nil {
+		l.timer.Stop()
+		l.timer = nil
+	}
+}
+
+// schedule 按绝对时间设置下一帧的定时器,到期后投递到EventQueue
+func (l *FrameLoop) schedule() {
+	gen := l.gen
+	next := l.base.Add(time.Duration(l.frame) * l.interval)
+	l.timer = l.tw.atFunc(timeToNs(next), "", func() {
+		l.q.Post(func() { l.run(gen) }, "frame")
+	})
+}
+
+// due 到now为止应执行到的帧数
+func (l *FrameLoop) due(now time.Time) uint64 {
+	if now.Before(l.base) {
+		return 0
+	}
+	return uint64(now.Sub(l.base)/l.interval) + 1
+}
+
+func (l *FrameLoop) run(gen uint64) {
+	ran := 0
+	for {
+		l.mux.Lock()
+		if gen != l.gen {
+			l.mux.Unlock()
+			return
+		}
+		due := l.due(l.now())
+		if l.frame >= due {
+			l.schedule()
+			l.mux.Unlock()
+			return
+		}
+		// 落后太多时跳过最早的帧
+		var skipped uint64
+		if allowed := uint64(l.opt.maxCatchUp - ran); due-l.frame > allowed {
+			skipped = due - l.frame - allowed
+			l.frame += skipped
+			l.stats.Skipped += skipped
+		}
+		if l.frame >= due {
+			l.schedule()
+			l.mux.Unlock()
+			l.skip(skipped)
+			return
+		}
+		frame := l.frame
+		l.frame++
+		l.mux.Unlock()
+
+		l.skip(skipped)
+		l.exec(gen, frame)
+		ran++
+	}
+}
+
+func (l *FrameLoop) skip(skipped uint64) {
+	if skipped > 0 && l.opt.onSkip != nil {
+		l.opt.onSkip(skipped)
+	}
+}
+
+// exec 执行一帧并统计耗时,回调panic时设置下一帧后再交给EventQueue处理
+func (l *FrameLoop) exec(gen uint64, frame uint64) {
+	start := l.now()
+	defer func() {
+		cost := l.now().Sub(start)
+		l.mux.Lock()
+		defer l.mux.Unlock()
+		l.stats.Executed++
+		l.stats.Last = cost
+		l.total += cost
+		if cost > l.stats.Max {
+			l.stats.Max = cost
+		}
+		if cost > l.interval {
+			l.stats.Overruns++
+		}
+		if r := recover(); r != nil {
+			if gen == l.gen {
+				l.schedule()
+			}
+			panic(r)
+		}
+	}()
+	l.f(frame)
+}
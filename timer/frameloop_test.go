@@ -0,0 +1,154 @@
+package timer
+
+import (
+	"fmt"
+	"jnet/base/queue"
+	"strings"
+	"testing"
+	"time"
+)
+
+type frameRun struct {
+	frame uint64
+	at    time.Duration
+}
+
+func newTestFrameLoop(t *testing.T, q queue.EventQueue, f func(frame uint64), opts ...FrameOption) (*FrameLoop, *TimingWheel, *FakeClock, queue.EventQueue) {
+	tw, clock := newFakeWheel(t)
+	if q == nil {
+		q = queue.NewEventQueue()
+	}
+	q.StartLoop()
+	t.Cleanup(q.Stop)
+	l := NewFrameLoop(tw, q, 50*time.Millisecond, f, opts...)
+	return l, tw, clock, q
+}
+
+func expectFrames(t *testing.T, runs chan frameRun, want ...frameRun) {
+	t.Helper()
+	for _, w := range want {
+		select {
+		case got := <-runs:
+			if got != w {
+				t.Fatalf("frame %+v, want %+v", got, w)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("frame %d not run", w.frame)
+		}
+	}
+}
+
+// waitIdle 时间轮和EventQueue都处理完已到期的任务
+func waitIdle(t *testing.T, tw *TimingWheel, clock *FakeClock, q queue.EventQueue) {
+	waitSentinel(t, tw, clock)
+	done := make(chan struct{})
+	q.Post(func() { close(done) }, "test")
+	<-done
+}
+
+func TestFrameLoopFixedRate(t *testing.T) {
+	runs := make(chan frameRun, 10)
+	var clock *FakeClock
+	var start time.Time
+	l, _, clock, _ := newTestFrameLoop(t, nil, func(frame uint64) {
+		runs <- frameRun{frame, clock.Now().Sub(start)}
+		if frame == 0 {
+			clock.Advance(10 * time.Millisecond) //模拟耗时,不影响下一帧的时间
+		}
+	})
+	start = clock.Now()
+	l.Start()
+	clock.Advance(50 * time.Millisecond)
+	expectFrames(t, runs, frameRun{0, 50 * time.Millisecond})
+	clock.Advance(40 * time.Millisecond)
+	expectFrames(t, runs, frameRun{1, 100 * time.Millisecond})
+	clock.Advance(50 * time.Millisecond)
+	expectFrames(t, runs, frameRun{2, 150 * time.Millisecond})
+	s := l.Stats()
+	if s.Frame != 3 || s.Executed != 3 || s.Skipped != 0 || s.Max != 10*time.Millisecond || s.Overruns != 0 {
+		t.Fatalf("stats %+v", s)
+	}
+}
+
+func TestFrameLoopCatchUp(t *testing.T) {
+	runs := make(chan frameRun, 20)
+	skips := make(chan uint64, 1)
+	var clock *FakeClock
+	var start time.Time
+	l, tw, clock, q := newTestFrameLoop(t, nil, func(frame uint64) {
+		runs <- frameRun{frame, clock.Now().Sub(start)}
+	}, WithMaxCatchUp(3), WithSkipHandler(func(n uint64) { skips <- n }))
+	start = clock.Now()
+	l.Start()
+	// 卡顿了10帧,跳过最早的7帧,追赶3帧
+	clock.Advance(500 * time.Millisecond)
+	expectFrames(t, runs, frameRun{7, 500 * time.Millisecond}, frameRun{8, 500 * time.Millisecond}, frameRun{9, 500 * time.Millisecond})
+	if n := <-skips; n != 7 {
+		t.Fatalf("skipped %d", n)
+	}
+	clock.Advance(50 * time.Millisecond)
+	expectFrames(t, runs, frameRun{10, 550 * time.Millisecond})
+	l.Stop()
+	waitIdle(t, tw, clock, q)
+	if len(runs) != 0 {
+		t.Fatal("frame run after Stop")
+	}
+	if s := l.Stats(); s.Executed != 4 || s.Skipped != 7 || s.Frame != 11 {
+		t.Fatalf("stats %+v", s)
+	}
+}
+
+func TestFrameLoopPauseResume(t *testing.T) {
+	runs := make(chan frameRun, 10)
+	var clock *FakeClock
+	var start time.Time
+	l, tw, clock, q := newTestFrameLoop(t, nil, func(frame uint64) {
+		runs <- frameRun{frame, clock.Now().Sub(start)}
+	})
+	start = clock.Now()
+	l.Start()
+	clock.Advance(50 * time.Millisecond)
+	expectFrames(t, runs, frameRun{0, 50 * time.Millisecond})
+	l.Pause()
+	clock.Advance(time.Second)
+	waitIdle(t, tw, clock, q)
+	if len(runs) != 0 || !l.Paused() {
+		t.Fatal("frame run while paused")
+	}
+	// 恢复后一个帧间隔执行下一帧,暂停期间不计为跳过
+	now := clock.Now().Sub(start)
+	l.Resume()
+	clock.Advance(50 * time.Millisecond)
+	expectFrames(t, runs, frameRun{1, now + 50*time.Millisecond})
+	if s := l.Stats(); s.Skipped != 0 || s.Executed != 2 {
+		t.Fatalf("stats %+v", s)
+	}
+}
+
+func TestFrameLoopPanic(t *testing.T) {
+	runs := make(chan uint64, 10)
+	panics := make(chan interface{}, 1)
+	q := queue.NewEventQueue()
+	q.SetPanicHandler(func(err interface{}) { panics <- err })
+	l, _, clock, _ := newTestFrameLoop(t, q, func(frame uint64) {
+		runs <- frame
+		if frame == 0 {
+			panic("bad frame")
+		}
+	})
+	l.Start()
+	clock.Advance(50 * time.Millisecond)
+	if <-runs != 0 || !strings.HasPrefix(fmt.Sprint(<-panics), "bad frame") {
+		t.Fatal("panic not reported")
+	}
+	// panic后继续执行之后的帧
+	clock.Advance(50 * time.Millisecond)
+	select {
+	case frame := <-runs:
+		if frame != 1 {
+			t.Fatalf("frame %d", frame)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("loop stopped after a panic")
+	}
+}
@@ -0,0 +1,103 @@
+package timer
+
+import (
+	"jnet/log"
+	"time"
+)
+
+// WaitMode DelayQueue等待下一个时间格到期的方式
+type WaitMode int
+
+const (
+	WaitSleep  WaitMode = iota //使用系统定时器,精度受调度影响,约为毫秒级
+	WaitSpin                   //忙等,占用一个CPU,延迟最低
+	WaitHybrid                 //睡眠到到期前spinThreshold,之后忙等
+)
+
+const (
+	minTick              = time.Microsecond
+	defaultSpinThreshold = time.Millisecond
+)
+
+type Option func(o *options)
+
+// Logger 持久化定时器及FileStore的日志输出
+type Logger interface {
+	Warnf(format string, args ...interface{})
+	Errorf(format string, args ...interface{})
+}
+
+type options struct {
+	executor     Executor
+	panicHandler PanicHandler
+	clock        Clock
+	name         string
+	logger       Logger
+
+	waitMode      WaitMode
+	spinThreshold time.Duration
+}
+
+func loadOptions(opts []Option) *options {
+	o := &options{
+		executor:     InlineExecutor,
+		panicHandler: LogPanic,
+		clock:        RealClock,
+		logger:       log.Module("timer"),
+
+		spinThreshold: defaultSpinThreshold,
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
+
+// WithExecutor 到期任务的执行方式,默认InlineExecutor
+func WithExecutor(e Executor) Option {
+	return func(o *options) {
+		o.executor = e
+	}
+}
+
+// WithPanicHandler 任务panic时的处理,默认LogPanic
+func WithPanicHandler(h PanicHandler) Option {
+	return func(o *options) {
+		o.panicHandler = h
+	}
+}
+
+// WithClock 时间来源,默认RealClock,测试中使用FakeClock
+func WithClock(c Clock) Option {
+	return func(o *options) {
+		o.clock = c
+	}
+}
+
+// WithWaitMode 等待方式,默认WaitSleep,tick小于1ms时需要WaitSpin或WaitHybrid
+func WithWaitMode(mode WaitMode) Option {
+	return func(o *options) {
+		o.waitMode = mode
+	}
+}
+
+// WithSpinThreshold WaitHybrid模式下到期前多久开始忙等,默认1ms
+func WithSpinThreshold(d time.Duration) Option {
+	return func(o *options) {
+		o.spinThreshold = d
+	}
+}
+
+// WithName 时间轮名字,作为指标的wheel标签区分多个时间轮
+func WithName(name string) Option {
+	return func(o *options) {
+		o.name = name
+	}
+}
+
+// WithLogger 日志输出,默认使用jnet/log的timer模块
+func WithLogger(l Logger) Option {
+	return func(o *options) {
+		o.logger = l
+	}
+}
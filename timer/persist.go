@@ -0,0 +1,178 @@
+package timer
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	jsoniter "github.com/json-iterator/go"
+)
+
+var json = jsoniter.ConfigCompatibleWithStandardLibrary
+
+// TaskFunc 持久化定时器的回调,payload为调度时传入的数据
+type TaskFunc func(id uint64, payload []byte)
+
+// TimerRecord 持久化的定时器
+type TimerRecord struct {
+	ID         uint64 `json:"id"`
+	Type       string `json:"type"`
+	Payload    []byte `json:"payload,omitempty"`
+	Expiration int64  `json:"expiration"` //纳秒时间戳
+}
+
+// TimerStore 保存未执行的定时器,调度时Save,取消或执行完成后Delete
+type TimerStore interface {
+	Save(r *TimerRecord) error
+	Delete(id uint64) error
+	Load() ([]*TimerRecord, error)
+	Close() error
+}
+
+// PersistentWheel 在TimingWheel之上调度可持久化的定时器,重启后通过Recover恢复;
+// 任务执行完成后才从store删除,执行过程中宕机重启会再执行一次
+type PersistentWheel struct {
+	tw     *TimingWheel
+	store  TimerStore
+	mux    sync.Mutex
+	tasks  map[string]TaskFunc
+	timers map[uint64]*Timer
+	nextID uint64
+}
+
+func NewPersistentWheel(tw *TimingWheel, store TimerStore) *PersistentWheel {
+	return &PersistentWheel{
+		tw:     tw,
+		store:  store,
+		tasks:  make(map[string]TaskFunc),
+		timers: make(map[uint64]*Timer),
+		//以启动时间为起点,已取消或执行的id重启后也不会被重新使用
+		nextID: uint64(tw.opt.clock.Now().UnixNano()),
+	}
+}
+
+// Register 注册任务类型,需在Recover之前完成
+func (p *PersistentWheel) Register(typ string, f TaskFunc) {
+	p.mux.Lock()
+	defer p.mux.Unlock()
+	p.tasks[typ] = f
+}
+
+// Recover 加载store中的定时器重新调度,停服期间已到期的按到期时间顺序立即执行;
+// 未注册类型的记录保留在store中,等待之后的版本处理
+func (p *PersistentWheel) Recover() error {
+	records, err := p.store.Load()
+	if err != nil {
+		return err
+	}
+	sort.Slice(records, func(i, j int) bool {
+		if records[i].Expiration != records[j].Expiration {
+			return records[i].Expiration < records[j].Expiration
+		}
+		return records[i].ID < records[j].ID
+	})
+	p.mux.Lock()
+	for _, r := range records {
+		if r.ID >= p.nextID {
+			p.nextID = r.ID + 1
+		}
+	}
+	p.mux.Unlock()
+	for _, r := range records {
+		if err := p.schedule(r); err != nil {
+			p.tw.opt.logger.Errorf("timer: recover %d: %v", r.ID, err)
+		}
+	}
+	return nil
+}
+
+// AfterFunc d之后执行typ类型的任务,返回的id用于Cancel,重启后仍然有效
+func (p *PersistentWheel) AfterFunc(d time.Duration, typ string, payload []byte) (uint64, error) {
+	return p.At(p.tw.opt.clock.Now().Add(d), typ, payload)
+}
+
+// At 在指定时间执行typ类型的任务
+func (p *PersistentWheel) At(at time.Time, typ string, payload []byte) (uint64, error) {
+	if err := p.checkType(typ); err != nil {
+		return 0, err
+	}
+	p.mux.Lock()
+	id := p.nextID
+	p.nextID++
+	p.mux.Unlock()
+	r := &TimerRecord{
+		ID:         id,
+		Type:       typ,
+		Payload:    payload,
+		Expiration: at.UnixNano(),
+	}
+	if err := p.store.Save(r); err != nil {
+		return 0, err
+	}
+	return id, p.schedule(r)
+}
+
+// Cancel 取消未执行的定时器,返回false表示不存在或已开始执行
+func (p *PersistentWheel) Cancel(id uint64) bool {
+	p.mux.Lock()
+	t, ok := p.timers[id]
+	delete(p.timers, id)
+	p.mux.Unlock()
+	if !ok || !t.Stop() {
+		return false
+	}
+	if err := p.store.Delete(id); err != nil {
+		p.tw.opt.logger.Errorf("timer: delete %d: %v", id, err)
+	}
+	return true
+}
+
+// Len 未执行的定时器数量
+func (p *PersistentWheel) Len() int {
+	p.mux.Lock()
+	defer p.mux.Unlock()
+	return len(p.timers)
+}
+
+// Close 关闭store,不停止TimingWheel
+func (p *PersistentWheel) Close() error {
+	return p.store.Close()
+}
+
+func (p *PersistentWheel) checkType(typ string) error {
+	p.mux.Lock()
+	defer p.mux.Unlock()
+	if _, ok := p.tasks[typ]; !ok {
+		return fmt.Errorf("timer: task type %q not registered", typ)
+	}
+	return nil
+}
+
+func (p *PersistentWheel) schedule(r *TimerRecord) error {
+	p.mux.Lock()
+	f, ok := p.tasks[r.Type]
+	if !ok {
+		p.mux.Unlock()
+		return fmt.Errorf("timer: task type %q not registered", r.Type)
+	}
+	t := &Timer{
+		expiration: r.Expiration,
+		tw:         p.tw,
+	}
+	t.task = func() {
+		p.mux.Lock()
+		delete(p.timers, r.ID)
+		p.mux.Unlock()
+		defer func() {
+			if err := p.store.Delete(r.ID); err != nil {
+				p.tw.opt.logger.Errorf("timer: delete %d: %v", r.ID, err)
+			}
+		}()
+		f(r.ID, r.Payload)
+	}
+	p.timers[r.ID] = t
+	p.mux.Unlock()
+	p.tw.addOrRun(t)
+	return nil
+}
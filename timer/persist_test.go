@@ -0,0 +1,178 @@
+package timer
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+// testLogger 记录输出的日志
+type testLogger struct {
+	mux   sync.Mutex
+	lines []string
+}
+
+func (l *testLogger) Warnf(format string, args ...interface{}) {
+	l.add(format, args...)
+}
+
+func (l *testLogger) Errorf(format string, args ...interface{}) {
+	l.add(format, args...)
+}
+
+func (l *testLogger) add(format string, args ...interface{}) {
+	l.mux.Lock()
+	defer l.mux.Unlock()
+	l.lines = append(l.lines, fmt.Sprintf(format, args...))
+}
+
+func (l *testLogger) count() int {
+	l.mux.Lock()
+	defer l.mux.Unlock()
+	return len(l.lines)
+}
+
+func TestPersistentWheelRecover(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "timers.log")
+	start := time.Date(2021, 3, 4, 10, 0, 0, 0, time.UTC)
+	fired := make(chan string, 10)
+	newWheel := func(now time.Time) (*PersistentWheel, *FakeClock) {
+		clock := NewFakeClock(now)
+		logger := &testLogger{}
+		tw, _ := NewTimingWheel(time.Millisecond, 10, WithClock(clock), WithLogger(logger))
+		tw.Start()
+		t.Cleanup(tw.Stop)
+		store, err := NewFileStore(path, logger)
+		if err != nil {
+			t.Fatal(err)
+		}
+		p := NewPersistentWheel(tw, store)
+		p.Register("build", func(id uint64, payload []byte) { fired <- string(payload) })
+		return p, clock
+	}
+
+	p, _ := newWheel(start)
+	var lastID uint64
+	for _, c := range []struct {
+		d       time.Duration
+		payload string
+	}{{10 * time.Second, "barracks"}, {5 * time.Second, "farm"}, {time.Hour, "castle"}, {20 * time.Second, "wall"}} {
+		id, err := p.AfterFunc(c.d, "build", []byte(c.payload))
+		if err != nil {
+			t.Fatal(err)
+		}
+		lastID = id
+		if c.payload == "wall" && !p.Cancel(id) {
+			t.Fatal("Cancel = false")
+		}
+	}
+	if _, err := p.AfterFunc(time.Second, "unknown", nil); err == nil {
+		t.Fatal("unregistered task type accepted")
+	}
+	_ = p.Close()
+
+	// 停服30秒后重启,期间到期的按顺序立即执行
+	p, clock := newWheel(start.Add(30 * time.Second))
+	if err := p.Recover(); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"farm", "barracks"} {
+		select {
+		case got := <-fired:
+			if got != want {
+				t.Fatalf("fired %q, want %q", got, want)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("%q not fired after recover", want)
+		}
+	}
+	if p.Len() != 1 {
+		t.Fatalf("Len = %d after recover", p.Len())
+	}
+	if id, _ := p.AfterFunc(time.Hour, "build", []byte("tower")); id <= lastID {
+		t.Fatalf("new id %d reused", id)
+	}
+	clock.Advance(time.Hour)
+	for i := 0; i < 2; i++ {
+		select {
+		case <-fired:
+		case <-time.After(time.Second):
+			t.Fatal("timer not fired")
+		}
+	}
+	_ = p.Close()
+	store, err := NewFileStore(path, &testLogger{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+	if records, _ := store.Load(); len(records) != 0 {
+		t.Fatalf("%d records left after all timers fired", len(records))
+	}
+}
+
+func TestFileStoreSkipsTornRecord(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "timers.log")
+	data := `{"op":"add","record":{"id":1,"type":"build","expiration":1000}}` + "\n" +
+		`{"op":"add","record":{"id":2,"type":"build","expiration":2000}}` + "\n" +
+		`{"op":"del","id":1}` + "\n" +
+		`{"op":"add","record":{"id":3,"ty`
+	if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+	logger := &testLogger{}
+	s, err := NewFileStore(path, logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	records, _ := s.Load()
+	if len(records) != 1 || records[0].ID != 2 {
+		t.Fatalf("records %+v", records)
+	}
+	if n := logger.count(); n != 1 {
+		t.Fatalf("%d warnings for one torn record", n)
+	}
+	// 打开时已压缩,之后追加的记录不会接在半行后面
+	_ = s.Save(&TimerRecord{ID: 4, Type: "build", Expiration: 3000})
+	s2, err := NewFileStore(path, logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s2.Close()
+	if records, _ = s2.Load(); len(records) != 2 {
+		t.Fatalf("records after reopen %+v", records)
+	}
+}
+
+func TestPersistentWheelSubMillisecond(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "timers.log")
+	start := time.Date(2021, 3, 4, 10, 0, 0, 0, time.UTC)
+	clock := NewFakeClock(start)
+	tw, _ := NewTimingWheel(100*time.Microsecond, 10, WithClock(clock), WithWaitMode(WaitSpin))
+	tw.Start()
+	defer tw.Stop()
+	store, err := NewFileStore(path, &testLogger{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := NewPersistentWheel(tw, store)
+	p.Register("build", func(id uint64, payload []byte) {})
+	at := start.Add(1500 * time.Microsecond)
+	if _, err := p.At(at, "build", nil); err != nil {
+		t.Fatal(err)
+	}
+	_ = p.Close()
+	s, err := NewFileStore(path, &testLogger{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	// 重启后恢复的到期时间不截断到毫秒
+	if records, _ := s.Load(); len(records) != 1 || records[0].Expiration != at.UnixNano() {
+		t.Fatalf("records %+v, want expiration %d", records, at.UnixNano())
+	}
+}
@@ -0,0 +1,97 @@
+package timer
+
+import (
+	"jnet/metrics"
+	"sync/atomic"
+	"time"
+)
+
+// LevelStats 一层时间轮的占用情况
+type LevelStats struct {
+	Tick     time.Duration `json:"tick"`
+	Buckets  int           `json:"buckets"`
+	Occupied int           `json:"occupied"` //有定时器的时间格数
+	Timers   int           `json:"timers"`
+}
+
+type WheelStats struct {
+	Pending        int          `json:"pending"`
+	NextExpiration time.Time    `json:"next_expiration"` //没有定时器时为零值
+	QueuedBuckets  int64        `json:"queued_buckets"`  //DelayQueue中等待到期的时间格
+	Levels         []LevelStats `json:"levels"`
+}
+
+// levels 由低到高的各层时间轮
+func (tw *TimingWheel) levels() []*TimingWheel {
+	var wheels []*TimingWheel
+	for w := tw; w != nil; w = (*TimingWheel)(atomic.LoadPointer(&w.overflowWheel)) {
+		wheels = append(wheels, w)
+	}
+	return wheels
+}
+
+// Levels 各层时间轮的占用情况,需要遍历所有时间格
+func (tw *TimingWheel) Levels() []LevelStats {
+	wheels := tw.levels()
+	stats := make([]LevelStats, 0, len(wheels))
+	for _, w := range wheels {
+		s := LevelStats{
+			Tick:    time.Duration(w.tick),
+			Buckets: len(w.buckets),
+		}
+		for _, b := range w.buckets {
+			if n := b.Len(); n > 0 {
+				s.Occupied++
+				s.Timers += n
+			}
+		}
+		stats = append(stats, s)
+	}
+	return stats
+}
+
+// Pending 在时间格中等待的定时器数量,不含正在执行和正在转移到下层的
+func (tw *TimingWheel) Pending() int {
+	n := 0
+	for _, s := range tw.Levels() {
+		n += s.Timers
+	}
+	return n
+}
+
+// collect 采集时读取等待中的定时器数,设置了名字时按时间轮区分
+func (tw *TimingWheel) collect(sink metrics.Sink) {
+	if tw.opt.name == "" {
+		sink.AddGauge(metricPending, float64(tw.Pending()))
+		return
+	}
+	sink.AddGauge(metricPending, float64(tw.Pending()), "wheel", tw.opt.name)
+}
+
+// NextExpiration 最早到期的定时器的到期时间
+func (tw *TimingWheel) NextExpiration() (time.Time, bool) {
+	next := int64(-1)
+	for _, w := range tw.levels() {
+		for _, b := range w.buckets {
+			if exp, ok := b.minExpiration(); ok && (next < 0 || exp < next) {
+				next = exp
+			}
+		}
+	}
+	if next < 0 {
+		return time.Time{}, false
+	}
+	return nsToTime(next), true
+}
+
+func (tw *TimingWheel) Stats() WheelStats {
+	s := WheelStats{
+		QueuedBuckets: tw.delayQueue.Len(),
+		Levels:        tw.Levels(),
+	}
+	for _, l := range s.Levels {
+		s.Pending += l.Timers
+	}
+	s.NextExpiration, _ = tw.NextExpiration()
+	return s
+}
@@ -0,0 +1,94 @@
+package timer
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// tagIndex 按标签索引未执行完的定时器,周期任务停止前一直在索引中
+type tagIndex struct {
+	mux    sync.Mutex
+	timers map[string]map[*Timer]struct{}
+}
+
+func newTagIndex() *tagIndex {
+	return &tagIndex{timers: make(map[string]map[*Timer]struct{})}
+}
+
+func (i *tagIndex) add(t *Timer) {
+	i.mux.Lock()
+	defer i.mux.Unlock()
+	m, ok := i.timers[t.tag]
+	if !ok {
+		m = make(map[*Timer]struct{})
+		i.timers[t.tag] = m
+	}
+	m[t] = struct{}{}
+}
+
+func (i *tagIndex) remove(t *Timer) {
+	i.mux.Lock()
+	defer i.mux.Unlock()
+	if m, ok := i.timers[t.tag]; ok {
+		delete(m, t)
+		if len(m) == 0 {
+			delete(i.timers, t.tag)
+		}
+	}
+}
+
+func (i *tagIndex) list(tag string) []*Timer {
+	i.mux.Lock()
+	defer i.mux.Unlock()
+	timers := make([]*Timer, 0, len(i.timers[tag]))
+	for t := range i.timers[tag] {
+		timers = append(timers, t)
+	}
+	return timers
+}
+
+// AfterFuncWithTag 同AfterFunc,定时器可按标签查询和取消,如标签为玩家id,下线时CancelTag
+func (tw *TimingWheel) AfterFuncWithTag(tag string, d time.Duration, f func()) *Timer {
+	return tw.afterFunc(d, tag, f)
+}
+
+// ScheduleFuncWithTag 同ScheduleFunc,定时器可按标签查询和取消
+func (tw *TimingWheel) ScheduleFuncWithTag(tag string, s Scheduler, f func()) *Timer {
+	return tw.scheduleFunc(s, tag, f)
+}
+
+// TimersByTag 标签下未执行或周期执行中的定时器,按到期时间排序
+func (tw *TimingWheel) TimersByTag(tag string) []*Timer {
+	timers := tw.tags.list(tag)
+	exp := make(map[*Timer]int64, len(timers))
+	for _, t := range timers {
+		exp[t] = t.getExpiration()
+	}
+	sort.Slice(timers, func(i, j int) bool {
+		return exp[timers[i]] < exp[timers[j]]
+	})
+	return timers
+}
+
+// Tags 各标签下的定时器数量
+func (tw *TimingWheel) Tags() map[string]int {
+	tw.tags.mux.Lock()
+	defer tw.tags.mux.Unlock()
+	tags := make(map[string]int, len(tw.tags.timers))
+	for tag, m := range tw.tags.timers {
+		tags[tag] = len(m)
+	}
+	return tags
+}
+
+// CancelTag 停止标签下的所有定时器,返回阻止执行的数量
+func (tw *TimingWheel) CancelTag(tag string) int {
+	n := 0
+	for _, t := range tw.tags.list(tag) {
+		if t.Stop() {
+			n++
+		}
+	}
+	return n
+}
@@ -2,17 +2,18 @@ package timer
 
 import (
 	"errors"
-	"fmt"
-	"os"
-	"runtime/debug"
+	"jnet/metrics"
+	"math"
 	"sync"
 	"sync/atomic"
 	"time"
 	"unsafe"
 )
 
+const metricPending = "jnet_timer_pending"
+
 type TimingWheel struct {
-	tick          int64 //时间跨度
+	tick          int64 //时间跨度,纳秒
 	wheelSize     int64
 	interval      int64
 	currentTime   int64     //时间跨度整数倍
@@ -21,34 +22,57 @@ type TimingWheel struct {
 	exitC         chan struct{}
 	waitGroup     sync.WaitGroup
 	overflowWheel unsafe.Pointer //*TimingWheel 指向更高层的时间轮
-}
-
-func NewTimingWheel(tick time.Duration, wheelSize int64) (*TimingWheel, error) {
-	tickMs := int64(tick / time.Millisecond)
-	if tick < 0 {
-		return nil, errors.New("tick must greater than 1ms")
+	opt           *options       //各层时间轮共用
+	tags          *tagIndex      //只在最底层时间轮
+	unregister    func()         //注销指标采集
+}
+
+// NewTimingWheel 默认WaitSleep模式tick不能小于1ms,更小的tick需要WithWaitMode(WaitSpin或WaitHybrid)
+func NewTimingWheel(tick time.Duration, wheelSize int64, opts ...Option) (*TimingWheel, error) {
+	opt := loadOptions(opts)
+	if opt.waitMode == WaitSleep && tick < time.Millisecond {
+		return nil, errors.New("tick must be at least 1ms, use WaitSpin or WaitHybrid for a smaller tick")
+	} else if tick < minTick {
+		return nil, errors.New("tick must be at least 1µs")
+	}
+	if wheelSize <= 0 {
+		return nil, errors.New("wheelSize must be positive")
 	}
-	startMs := timeToMs(time.Now().UTC())
-	return newTimingWheel(tickMs, startMs, wheelSize, NewDelayQueue(int(wheelSize))), nil
+	dq := NewDelayQueue(int(wheelSize))
+	dq.clock = opt.clock
+	dq.waitMode = opt.waitMode
+	dq.spinThreshold = opt.spinThreshold
+	startNs := timeToNs(opt.clock.Now())
+	tw := newTimingWheel(int64(tick), startNs, wheelSize, dq, opt)
+	tw.tags = newTagIndex()
+	return tw, nil
 }
 
-func newTimingWheel(tickMs int64, startMs int64, wheelSize int64, dq *DelayQueue) *TimingWheel {
+func newTimingWheel(tick int64, startNs int64, wheelSize int64, dq *DelayQueue, opt *options) *TimingWheel {
 	buckets := make([]*bucket, wheelSize)
 	for i := range buckets {
 		buckets[i] = newBucket()
 	}
+	currentTime := truncate(startNs, tick) //修剪为tick 的整数倍
+	interval := tick * wheelSize
+	//纳秒计时的高层时间轮可能溢出,作为最高层容纳之后所有的定时器
+	if interval/wheelSize != tick || currentTime+interval < currentTime {
+		interval = math.MaxInt64 - currentTime
+	}
 	return &TimingWheel{
-		tick:        tickMs,
+		tick:        tick,
 		wheelSize:   wheelSize,
-		interval:    tickMs * wheelSize,
-		currentTime: truncate(startMs, tickMs), //修剪为tickMs 的整数倍
+		interval:    interval,
+		currentTime: currentTime,
 		buckets:     buckets,
 		delayQueue:  dq,
 		exitC:       make(chan struct{}),
+		opt:         opt,
 	}
 }
 
 func (tw *TimingWheel) Start() {
+	tw.unregister = metrics.RegisterCollector(tw.collect)
 	tw.asyncRun(func() {
 		tw.delayQueue.Poll()
 	})
@@ -58,9 +82,27 @@ func (tw *TimingWheel) Start() {
 }
 
 func (tw *TimingWheel) AfterFunc(d time.Duration, f func()) *Timer {
+	return tw.afterFunc(d, "", f)
+}
+
+func (tw *TimingWheel) afterFunc(d time.Duration, tag string, f func()) *Timer {
+	return tw.atFunc(timeToNs(tw.opt.clock.Now().Add(d)), tag, f)
+}
+
+// atFunc 在指定的纳秒时间戳执行
+func (tw *TimingWheel) atFunc(expiration int64, tag string, f func()) *Timer {
 	t := &Timer{
-		expiration: timeToMs(time.Now().UTC().Add(d)),
+		expiration: expiration,
 		task:       f,
+		tw:         tw,
+		tag:        tag,
+	}
+	if tag != "" {
+		tw.tags.add(t)
+		t.task = func() {
+			tw.tags.remove(t)
+			f()
+		}
 	}
 	tw.addOrRun(t)
 	return t
@@ -71,6 +113,11 @@ func (tw *TimingWheel) consume() {
 		select {
 		case elem := <-tw.delayQueue.C:
 			e := elem.(*bucket)
+			//时间跳变或执行过慢时,Poll预先取出的时间格可能晚于上次级联新插入的,放回按顺序取出
+			if exp, ok := tw.delayQueue.peek(); ok && exp < e.Expiration() {
+				tw.delayQueue.Offer(e, e.Expiration())
+				continue
+			}
 			tw.advanceTime(e.Expiration())
 			e.Refresh(tw.addOrRun)
 		case <-tw.exitC:
@@ -94,23 +141,21 @@ func (tw *TimingWheel) advanceTime(expiration int64) {
 	}
 }
 
+// 新任务或过期时间格中的任务插入时间轮,已到期时执行
+// 已停止、已在时间格中(被Reset重新插入)或已执行的任务直接丢弃
 func (tw *TimingWheel) addOrRun(t *Timer) {
-	if !tw.add(t) {
-		defer func() {
-			err := recover()
-			if err != nil {
-				fmt.Fprintf(os.Stderr, "timer task %v paniced : %v\n", t, err)
-				debug.PrintStack()
-			}
-		}()
-		t.task() //回调至工作线程
+	t.mux.Lock()
+	if t.state != timerPending || t.getBucket() != nil || tw.add(t) {
+		t.mux.Unlock()
+		return
 	}
+	t.state = timerFiring
+	expiration := t.expiration
+	t.mux.Unlock()
+	tw.execute(t, expiration)
 }
 
 func (tw *TimingWheel) add(t *Timer) bool {
-	if atomic.LoadInt32(&t.canceled) == 1 {
-		return true
-	}
 	currentTime := atomic.LoadInt64(&tw.currentTime)
 	if t.expiration < currentTime+tw.tick {
 		return false
@@ -127,7 +172,7 @@ func (tw *TimingWheel) add(t *Timer) bool {
 		overflowWheel := atomic.LoadPointer(&tw.overflowWheel)
 		if overflowWheel == nil { //下一层时间跨度为上一层时间跨度的总长interval
 			atomic.CompareAndSwapPointer(&tw.overflowWheel, nil,
-				unsafe.Pointer(newTimingWheel(tw.interval, currentTime, tw.wheelSize, tw.delayQueue)))
+				unsafe.Pointer(newTimingWheel(tw.interval, currentTime, tw.wheelSize, tw.delayQueue, tw.opt)))
 			overflowWheel = atomic.LoadPointer(&tw.overflowWheel)
 		}
 		return (*TimingWheel)(overflowWheel).add(t)
@@ -143,6 +188,9 @@ func (tw *TimingWheel) asyncRun(cb func()) {
 }
 
 func (tw *TimingWheel) Stop() {
+	if tw.unregister != nil {
+		tw.unregister()
+	}
 	close(tw.exitC)
 	tw.waitGroup.Wait()
 }
@@ -159,31 +207,41 @@ type Scheduler interface {
 }
 
 func (tw *TimingWheel) ScheduleFunc(s Scheduler, f func()) (t *Timer) {
-	expiration := s.Next(time.Now().UTC())
+	return tw.scheduleFunc(s, "", f)
+}
+
+func (tw *TimingWheel) scheduleFunc(s Scheduler, tag string, f func()) (t *Timer) {
+	expiration := s.Next(tw.opt.clock.Now())
 	if expiration.IsZero() {
 		return
 	}
 	t = &Timer{
-		expiration: timeToMs(expiration),
-		task: func() {
-			expiration = s.Next(msToTime(t.expiration))
-			if !expiration.IsZero() {
-				t.expiration = timeToMs(expiration)
-				tw.addOrRun(t)
-			}
-			f()
-		},
+		expiration: timeToNs(expiration),
+		tw:         tw,
+		tag:        tag,
+	}
+	t.task = func() {
+		next := s.Next(nsToTime(t.getExpiration()))
+		if !next.IsZero() && t.rearm(timeToNs(next)) {
+			tw.addOrRun(t)
+		} else if tag != "" {
+			tw.tags.remove(t)
+		}
+		f()
+	}
+	if tag != "" {
+		tw.tags.add(t)
 	}
 	tw.addOrRun(t)
 	return
 }
 
-func timeToMs(t time.Time) int64 {
-	return t.UnixNano() / int64(time.Millisecond)
+func timeToNs(t time.Time) int64 {
+	return t.UnixNano()
 }
 
-func msToTime(t int64) time.Time {
-	return time.Unix(0, t*int64(time.Millisecond)).UTC()
+func nsToTime(t int64) time.Time {
+	return time.Unix(0, t).UTC()
 }
 
 type EveryScheduler struct {
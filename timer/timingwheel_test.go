@@ -1,23 +1,643 @@
 package timer
 
 import (
-	"fmt"
+	"bytes"
+	"jnet/base/queue"
+	"jnet/metrics"
+	"math/rand"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
 	//"github.com/RussellLuo/timingwheel"
 	"testing"
 	"time"
 )
 
 func BenchmarkNewTimingWheel(b *testing.B) {
-	tw, _ := NewTimingWheel(time.Millisecond, 100)
+	clock := NewFakeClock(time.Date(2021, 3, 4, 10, 0, 0, 0, time.UTC))
+	tw, _ := NewTimingWheel(time.Millisecond, 100, WithClock(clock))
 	tw.Start()
 	defer tw.Stop()
-	fmt.Printf("NOW timer  fires %v\n", time.Now())
-	var timerArray []*Timer
-	for i := 0; i < 1000000; i++ {
-		t := tw.ScheduleFunc(&EveryScheduler{10 * time.Millisecond}, func() {
-			fmt.Printf("The timer 10 MilliSecond fires %v\n", time.Now())
+	var fired int64
+	for i := 0; i < b.N; i++ {
+		tw.ScheduleFunc(&EveryScheduler{10 * time.Millisecond}, func() {
+			atomic.AddInt64(&fired, 1)
 		})
-		timerArray = append(timerArray, t)
 	}
-	time.Sleep(600 * time.Second)
+	b.ResetTimer()
+	// 虚拟时间推进1秒,每个定时器执行100次
+	for i := 0; i < 100; i++ {
+		clock.Advance(10 * time.Millisecond)
+		for atomic.LoadInt64(&fired) < int64(b.N*(i+1)) {
+			runtime.Gosched()
+		}
+	}
+}
+
+func newTestWheel(t *testing.T) *TimingWheel {
+	tw, err := NewTimingWheel(time.Millisecond, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tw
+}
+
+func TestTimerStopRemovesFromBucket(t *testing.T) {
+	tw := newTestWheel(t)
+	timer := tw.AfterFunc(time.Hour, func() { t.Error("stopped timer fired") })
+	b := timer.getBucket()
+	if b == nil || b.timers.Len() != 1 {
+		t.Fatal("timer not in a bucket")
+	}
+	if !timer.Stop() {
+		t.Fatal("Stop = false for a pending timer")
+	}
+	if timer.getBucket() != nil || b.timers.Len() != 0 {
+		t.Fatal("timer still in its bucket after Stop")
+	}
+	if timer.Stop() {
+		t.Fatal("second Stop = true")
+	}
+}
+
+// 模拟上层时间格到期后、重新插入下层时间轮之前调用Stop
+func TestTimerStopDuringCascade(t *testing.T) {
+	tw := newTestWheel(t)
+	var fired int32
+	timer := tw.AfterFunc(50*time.Millisecond, func() { atomic.AddInt32(&fired, 1) })
+	b := timer.getBucket()
+	if b == nil || b == tw.buckets[timer.expiration/tw.tick%tw.wheelSize] {
+		t.Fatal("timer should be in an overflow wheel")
+	}
+	var stopped bool
+	b.Refresh(func(t *Timer) {
+		stopped = t.Stop()
+		tw.addOrRun(t)
+	})
+	if !stopped || timer.getBucket() != nil || atomic.LoadInt32(&fired) != 0 {
+		t.Fatalf("stopped %v, bucket %v, fired %d", stopped, timer.getBucket(), fired)
+	}
+
+	// 已到期并开始执行后Stop返回false
+	timer = tw.AfterFunc(50*time.Millisecond, func() { atomic.AddInt32(&fired, 1) })
+	b = timer.getBucket()
+	timer.expiration = 0
+	b.Refresh(tw.addOrRun)
+	if timer.Stop() || atomic.LoadInt32(&fired) != 1 {
+		t.Fatalf("Stop after fire, fired %d", fired)
+	}
+}
+
+func TestTimerStopRace(t *testing.T) {
+	tw, clock := newFakeWheel(t)
+	const n = 2000
+	fired := make([]int32, n)
+	stopped := make([]int32, n)
+	timers := make([]*Timer, n)
+	for i := 0; i < n; i++ {
+		i := i
+		timers[i] = tw.AfterFunc(time.Duration(rand.Intn(40)+1)*time.Millisecond, func() {
+			atomic.AddInt32(&fired[i], 1)
+		})
+	}
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			if timers[i].Stop() {
+				atomic.StoreInt32(&stopped[i], 1)
+			}
+		}(i)
+	}
+	for i := 0; i < 40; i++ {
+		clock.Advance(time.Millisecond)
+	}
+	wg.Wait()
+	waitSentinel(t, tw, clock)
+	for i := 0; i < n; i++ {
+		f, s := atomic.LoadInt32(&fired[i]), atomic.LoadInt32(&stopped[i])
+		if (s == 1 && f != 0) || (s == 0 && f != 1) {
+			t.Fatalf("timer %d: stopped %d fired %d", i, s, f)
+		}
+	}
+}
+
+func TestTimerReset(t *testing.T) {
+	tw := newTestWheel(t)
+	tw.Start()
+	defer tw.Stop()
+	fired := make(chan struct{}, 2)
+	timer := tw.AfterFunc(time.Hour, func() { fired <- struct{}{} })
+	if !timer.Reset(5 * time.Millisecond) {
+		t.Fatal("Reset of a pending timer = false")
+	}
+	select {
+	case <-fired:
+	case <-time.After(time.Second):
+		t.Fatal("timer did not fire after Reset")
+	}
+	if timer.Reset(5 * time.Millisecond) {
+		t.Fatal("Reset of a fired timer = true")
+	}
+	select {
+	case <-fired:
+	case <-time.After(time.Second):
+		t.Fatal("timer did not fire after second Reset")
+	}
+}
+
+func TestScheduleFuncStop(t *testing.T) {
+	tw := newTestWheel(t)
+	tw.Start()
+	defer tw.Stop()
+	var fired int32
+	timer := tw.ScheduleFunc(&EveryScheduler{5 * time.Millisecond}, func() { atomic.AddInt32(&fired, 1) })
+	for atomic.LoadInt32(&fired) < 3 {
+		time.Sleep(time.Millisecond)
+	}
+	timer.Stop()
+	time.Sleep(5 * time.Millisecond) // 等待可能正在执行的一次
+	n := atomic.LoadInt32(&fired)
+	time.Sleep(30 * time.Millisecond)
+	if atomic.LoadInt32(&fired) != n {
+		t.Fatal("periodic timer fired after Stop")
+	}
+}
+
+func TestPoolExecutor(t *testing.T) {
+	pool := NewPoolExecutor(2, 16)
+	defer pool.Stop()
+	tw, _ := NewTimingWheel(time.Millisecond, 10, WithExecutor(pool))
+	tw.Start()
+	defer tw.Stop()
+	block := make(chan struct{})
+	defer close(block)
+	fired := make(chan struct{})
+	tw.AfterFunc(time.Millisecond, func() { <-block })
+	tw.AfterFunc(5*time.Millisecond, func() { close(fired) })
+	// 慢任务不影响其他定时器
+	select {
+	case <-fired:
+	case <-time.After(time.Second):
+		t.Fatal("timer delayed by a slow task")
+	}
+}
+
+func TestPoolExecutorStop(t *testing.T) {
+	pool := NewPoolExecutor(1, 16)
+	var mux sync.Mutex
+	var order []int
+	record := func(i int) func() {
+		return func() {
+			mux.Lock()
+			order = append(order, i)
+			mux.Unlock()
+		}
+	}
+	for i := 0; i < 10; i++ {
+		pool.Execute(record(i))
+	}
+	pool.Stop()
+	// Stop之后不panic,在调用者协程中按顺序执行
+	pool.Execute(record(10))
+	pool.Stop()
+	pool.Execute(record(11))
+	for i, v := range order {
+		if v != i {
+			t.Fatalf("order %v", order)
+		}
+	}
+	if len(order) != 12 {
+		t.Fatalf("order %v, want 12 tasks", order)
+	}
+}
+
+func TestQueueExecutor(t *testing.T) {
+	q := queue.NewEventQueue()
+	q.StartLoop()
+	defer q.Stop()
+	tw, _ := NewTimingWheel(time.Millisecond, 10, WithExecutor(QueueExecutor(q)))
+	tw.Start()
+	defer tw.Stop()
+	fired := make(chan struct{})
+	tw.AfterFunc(time.Millisecond, func() { close(fired) })
+	select {
+	case <-fired:
+	case <-time.After(time.Second):
+		t.Fatal("task not run by the event queue")
+	}
+}
+
+func TestPanicHandlerAndLag(t *testing.T) {
+	r := metrics.NewRegistry()
+	metrics.SetSink(r)
+	defer metrics.SetSink(nil)
+	panics := make(chan interface{}, 1)
+	tw, _ := NewTimingWheel(time.Millisecond, 10, WithPanicHandler(func(err interface{}, stack []byte) {
+		panics <- err
+	}))
+	tw.Start()
+	defer tw.Stop()
+	tw.AfterFunc(time.Millisecond, func() { panic("boom") })
+	select {
+	case err := <-panics:
+		if err != "boom" {
+			t.Fatalf("panic handler got %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("panic handler not called")
+	}
+	// panic后时间轮继续工作
+	fired := make(chan struct{})
+	tw.AfterFunc(time.Millisecond, func() { close(fired) })
+	select {
+	case <-fired:
+	case <-time.After(time.Second):
+		t.Fatal("timer wheel stopped after a panic")
+	}
+	if r.Value(metricPanics) != 1 {
+		t.Fatalf("%s = %v", metricPanics, r.Value(metricPanics))
+	}
+	var buf bytes.Buffer
+	_ = r.WriteText(&buf)
+	if !strings.Contains(buf.String(), metricLag+"_count 2") {
+		t.Fatalf("lag not observed:\n%s", buf.String())
+	}
+}
+
+func TestPendingMetric(t *testing.T) {
+	clock := NewFakeClock(time.Date(2021, 3, 4, 10, 0, 0, 0, time.UTC))
+	a, _ := NewTimingWheel(time.Millisecond, 10, WithClock(clock), WithName("a"))
+	b, _ := NewTimingWheel(time.Millisecond, 10, WithClock(clock), WithName("b"))
+	a.Start()
+	defer a.Stop()
+	b.Start()
+	a.AfterFunc(time.Second, func() {})
+	a.AfterFunc(time.Second, func() {})
+	b.AfterFunc(time.Second, func() {})
+	// 指标在定时器加入之后才启用
+	r := metrics.NewRegistry()
+	metrics.Collect(r)
+	if v := r.Value(metricPending, "wheel", "a"); v != 2 {
+		t.Fatalf("wheel a pending = %v, want 2", v)
+	}
+	if v := r.Value(metricPending, "wheel", "b"); v != 1 {
+		t.Fatalf("wheel b pending = %v, want 1", v)
+	}
+	b.Stop()
+	r = metrics.NewRegistry()
+	metrics.Collect(r)
+	if v := r.Value(metricPending, "wheel", "b"); v != 0 {
+		t.Fatalf("stopped wheel still collected: %v", v)
+	}
+}
+
+func newFakeWheel(t *testing.T) (*TimingWheel, *FakeClock) {
+	clock := NewFakeClock(time.Date(2021, 3, 4, 10, 0, 0, 0, time.UTC))
+	tw, err := NewTimingWheel(time.Millisecond, 10, WithClock(clock))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tw.Start()
+	t.Cleanup(tw.Stop)
+	return tw, clock
+}
+
+// waitSentinel 在之后添加一个定时器并推进时间等待其执行,
+// 返回时之前到期的定时器都已按顺序执行完
+func waitSentinel(t *testing.T, tw *TimingWheel, clock *FakeClock) {
+	done := make(chan struct{})
+	tw.AfterFunc(time.Hour, func() { close(done) })
+	clock.Advance(time.Hour)
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("sentinel timer did not fire")
+	}
+}
+
+func TestFakeClockFiringOrder(t *testing.T) {
+	tw, clock := newFakeWheel(t)
+	start := clock.Now()
+	var fired []time.Duration
+	// Inline执行,都在consume协程中,按执行顺序记录
+	for _, d := range []time.Duration{40, 3, 2500, 1, 250, 25, 3, 999} {
+		d := d * time.Millisecond
+		tw.AfterFunc(d, func() {
+			if now := clock.Now(); now.Before(start.Add(d)) {
+				t.Errorf("timer %v fired at %v", d, now.Sub(start))
+			}
+			fired = append(fired, d)
+		})
+	}
+	clock.Advance(3 * time.Second)
+	waitSentinel(t, tw, clock)
+	want := []time.Duration{1, 3, 3, 25, 40, 250, 999, 2500}
+	if len(fired) != len(want) {
+		t.Fatalf("fired %v", fired)
+	}
+	for i := range want {
+		if fired[i] != want[i]*time.Millisecond {
+			t.Fatalf("fired %v, want %v ms", fired, want)
+		}
+	}
+}
+
+func TestFakeClockCascade(t *testing.T) {
+	tw, clock := newFakeWheel(t)
+	start := clock.Now()
+	fired := make(chan time.Time, 1)
+	timer := tw.AfterFunc(2500*time.Millisecond, func() { fired <- clock.Now() })
+	// 1ms*10 -> 10ms*10 -> 100ms*10 -> 1s*10,位于第4层
+	overflow := tw
+	for i := 0; i < 3; i++ {
+		overflow = (*TimingWheel)(atomic.LoadPointer(&overflow.overflowWheel))
+	}
+	if timer.getBucket() != overflow.buckets[2] {
+		t.Fatal("timer not in the 4th level wheel")
+	}
+	for _, step := range []time.Duration{1999, 1, 499} {
+		clock.Advance(step * time.Millisecond)
+		clock.BlockUntil(1) //进入休眠等待下一个时间格
+		select {
+		case at := <-fired:
+			t.Fatalf("fired early at %v", at.Sub(start))
+		default:
+		}
+	}
+	// 2000ms时降到第3层,2500ms时所在时间格到期直接执行
+	l3 := (*TimingWheel)(atomic.LoadPointer(&tw.overflowWheel))
+	l3 = (*TimingWheel)(atomic.LoadPointer(&l3.overflowWheel))
+	if timer.getBucket() != l3.buckets[5] {
+		t.Fatal("timer not cascaded to the 3rd level wheel")
+	}
+	clock.Advance(time.Millisecond)
+	select {
+	case at := <-fired:
+		if d := at.Sub(start); d != 2500*time.Millisecond {
+			t.Fatalf("fired at %v", d)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("timer did not fire")
+	}
+}
+
+func TestFakeClockStopBeforeExpiration(t *testing.T) {
+	tw, clock := newFakeWheel(t)
+	var fired int32
+	timer := tw.AfterFunc(50*time.Millisecond, func() { atomic.AddInt32(&fired, 1) })
+	clock.Advance(49 * time.Millisecond)
+	clock.BlockUntil(1)
+	if !timer.Stop() {
+		t.Fatal("Stop = false before expiration")
+	}
+	if timer.Reset(10 * time.Millisecond) {
+		t.Fatal("Reset of a stopped timer = true")
+	}
+	clock.Advance(9 * time.Millisecond)
+	clock.BlockUntil(1)
+	if atomic.LoadInt32(&fired) != 0 {
+		t.Fatal("timer fired early")
+	}
+	waitSentinel(t, tw, clock)
+	if atomic.LoadInt32(&fired) != 1 {
+		t.Fatalf("fired %d times", fired)
+	}
+}
+
+func TestWheelIntrospection(t *testing.T) {
+	tw, clock := newFakeWheel(t)
+	start := clock.Now()
+	if _, ok := tw.NextExpiration(); ok || tw.Pending() != 0 {
+		t.Fatal("empty wheel reports timers")
+	}
+	first := tw.AfterFunc(5*time.Millisecond, func() {})
+	tw.AfterFunc(50*time.Millisecond, func() {})
+	tw.AfterFunc(55*time.Millisecond, func() {})
+	tw.AfterFunc(5*time.Second, func() {})
+	stats := tw.Stats()
+	if stats.Pending != 4 || stats.QueuedBuckets != 3 || !stats.NextExpiration.Equal(start.Add(5*time.Millisecond)) {
+		t.Fatalf("stats %+v", stats)
+	}
+	want := []LevelStats{
+		{Tick: time.Millisecond, Buckets: 10, Occupied: 1, Timers: 1},
+		{Tick: 10 * time.Millisecond, Buckets: 10, Occupied: 1, Timers: 2},
+		{Tick: 100 * time.Millisecond, Buckets: 10},
+		{Tick: time.Second, Buckets: 10, Occupied: 1, Timers: 1},
+	}
+	if len(stats.Levels) != len(want) {
+		t.Fatalf("levels %+v", stats.Levels)
+	}
+	for i := range want {
+		if stats.Levels[i] != want[i] {
+			t.Fatalf("level %d: %+v, want %+v", i, stats.Levels[i], want[i])
+		}
+	}
+	first.Stop()
+	if next, _ := tw.NextExpiration(); !next.Equal(start.Add(50*time.Millisecond)) || tw.Pending() != 3 {
+		t.Fatalf("next %v, pending %d after Stop", next.Sub(start), tw.Pending())
+	}
+}
+
+func TestTaggedTimers(t *testing.T) {
+	tw, clock := newFakeWheel(t)
+	fired := make(chan string, 10)
+	tw.AfterFuncWithTag("player:1", 10*time.Millisecond, func() { fired <- "build" })
+	tick := tw.ScheduleFuncWithTag("player:1", &EveryScheduler{20 * time.Millisecond}, func() { fired <- "regen" })
+	tw.AfterFuncWithTag("player:2", 3*time.Hour, func() { fired <- "other" })
+	tw.AfterFunc(time.Hour, func() {})
+	if tags := tw.Tags(); len(tags) != 2 || tags["player:1"] != 2 || tags["player:2"] != 1 {
+		t.Fatalf("tags %v", tags)
+	}
+	clock.Advance(10 * time.Millisecond)
+	if got := <-fired; got != "build" {
+		t.Fatalf("fired %q", got)
+	}
+	timers := tw.TimersByTag("player:1")
+	if len(timers) != 1 || timers[0] != tick || timers[0].Tag() != "player:1" {
+		t.Fatalf("timers after one-shot fired %v", timers)
+	}
+	if n := tw.CancelTag("player:1"); n != 1 {
+		t.Fatalf("CancelTag = %d", n)
+	}
+	if tags := tw.Tags(); len(tags) != 1 || tags["player:2"] != 1 {
+		t.Fatalf("tags after cancel %v", tags)
+	}
+	waitSentinel(t, tw, clock)
+	select {
+	case got := <-fired:
+		t.Fatalf("%q fired after CancelTag", got)
+	default:
+	}
+}
+
+func TestNewTimingWheelValidation(t *testing.T) {
+	for _, c := range []struct {
+		tick time.Duration
+		size int64
+		opts []Option
+		ok   bool
+	}{
+		{time.Millisecond, 10, nil, true},
+		{500 * time.Microsecond, 10, nil, false},
+		{0, 10, nil, false},
+		{time.Millisecond, 0, nil, false},
+		{100 * time.Microsecond, 10, []Option{WithWaitMode(WaitHybrid)}, true},
+		{time.Microsecond, 10, []Option{WithWaitMode(WaitSpin)}, true},
+		{time.Nanosecond, 10, []Option{WithWaitMode(WaitSpin)}, false},
+	} {
+		if _, err := NewTimingWheel(c.tick, c.size, c.opts...); (err == nil) != c.ok {
+			t.Errorf("NewTimingWheel(%v, %d): %v", c.tick, c.size, err)
+		}
+	}
+}
+
+func TestHighResolutionWheel(t *testing.T) {
+	clock := NewFakeClock(time.Date(2021, 3, 4, 10, 0, 0, 0, time.UTC))
+	tw, err := NewTimingWheel(100*time.Microsecond, 10, WithClock(clock), WithWaitMode(WaitSpin))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tw.Start()
+	defer tw.Stop()
+	start := clock.Now()
+	fired := make(chan time.Duration, 4)
+	for _, d := range []time.Duration{1050, 250, 150, 900} {
+		tw.AfterFunc(d*time.Microsecond, func() { fired <- clock.Now().Sub(start) })
+	}
+	// 按100µs的时间格执行,每次推进后等待执行再继续
+	for _, at := range []time.Duration{100, 200, 900, 1000} {
+		clock.Set(start.Add(at * time.Microsecond))
+		select {
+		case got := <-fired:
+			if got != at*time.Microsecond {
+				t.Fatalf("fired at %v, want %v", got, at*time.Microsecond)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("no timer fired at %vµs", at)
+		}
+	}
+	waitSentinel(t, tw, clock)
+	if len(fired) != 0 {
+		t.Fatal("extra timer fired")
+	}
+	// 很远的定时器不会使纳秒计时的高层时间轮溢出
+	tw.AfterFunc(200*365*24*time.Hour, func() {})
+	if tw.Pending() != 1 {
+		t.Fatalf("pending %d", tw.Pending())
+	}
+}
+
+func TestHybridWaitMode(t *testing.T) {
+	tw, err := NewTimingWheel(100*time.Microsecond, 20, WithWaitMode(WaitHybrid), WithSpinThreshold(200*time.Microsecond))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tw.Start()
+	defer tw.Stop()
+	fired := make(chan time.Time, 1)
+	start := time.Now()
+	tw.AfterFunc(500*time.Microsecond, func() { fired <- time.Now() })
+	select {
+	case at := <-fired:
+		if d := at.Sub(start); d < 400*time.Microsecond {
+			t.Fatalf("fired after %v", d)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("timer did not fire")
+	}
+}
+
+func benchmarkJitter(b *testing.B, tick, delay time.Duration, mode WaitMode) {
+	tw, err := NewTimingWheel(tick, 64, WithWaitMode(mode))
+	if err != nil {
+		b.Fatal(err)
+	}
+	tw.Start()
+	defer tw.Stop()
+	jitters := make([]time.Duration, 0, b.N)
+	fired := make(chan time.Time, 1)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		expected := time.Now().Add(delay)
+		tw.AfterFunc(delay, func() { fired <- time.Now() })
+		j := (<-fired).Sub(expected)
+		if j < 0 {
+			j = -j
+		}
+		jitters = append(jitters, j)
+	}
+	b.StopTimer()
+	sort.Slice(jitters, func(i, j int) bool { return jitters[i] < jitters[j] })
+	var sum time.Duration
+	for _, j := range jitters {
+		sum += j
+	}
+	b.ReportMetric(float64(sum.Microseconds())/float64(len(jitters)), "avg-jitter-µs")
+	b.ReportMetric(float64(jitters[len(jitters)*99/100].Microseconds()), "p99-jitter-µs")
+}
+
+// 毫秒模式与高精度模式的执行误差对比
+func BenchmarkJitterSleep1ms(b *testing.B) {
+	benchmarkJitter(b, time.Millisecond, 2*time.Millisecond, WaitSleep)
+}
+
+func BenchmarkJitterHybrid100us(b *testing.B) {
+	benchmarkJitter(b, 100*time.Microsecond, 2*time.Millisecond, WaitHybrid)
+}
+
+func BenchmarkJitterSpin100us(b *testing.B) {
+	benchmarkJitter(b, 100*time.Microsecond, 2*time.Millisecond, WaitSpin)
+}
+
+// steppedClock 模拟时钟回拨: timer按单调时间触发,Now仍早于deadline
+type steppedClock struct {
+	now time.Time
+}
+
+func (c steppedClock) Now() time.Time {
+	return c.now
+}
+
+func (c steppedClock) NewTimerAt(deadline time.Time) ClockTimer {
+	t := firedTimer(make(chan time.Time, 1))
+	t <- c.now
+	return t
+}
+
+type firedTimer chan time.Time
+
+func (t firedTimer) C() <-chan time.Time {
+	return t
+}
+
+func (t firedTimer) Stop() bool {
+	return false
+}
+
+func TestWaitSleepClockStepBack(t *testing.T) {
+	for _, mode := range []WaitMode{WaitSleep, WaitHybrid} {
+		now := time.Now()
+		dq := NewDelayQueue(1)
+		dq.clock = steppedClock{now: now}
+		dq.waitMode = mode
+		dq.spinThreshold = time.Millisecond
+		dq.sleeping = 1
+		done := make(chan bool, 1)
+		go func() {
+			done <- dq.waitUntil(timeToNs(now.Add(time.Hour)))
+		}()
+		select {
+		case ok := <-done:
+			if !ok {
+				t.Fatalf("mode %v: waitUntil returned false", mode)
+			}
+		case <-time.After(time.Second):
+			close(dq.exitChan)
+			t.Fatalf("mode %v: waitUntil spins after the clock stepped back", mode)
+		}
+	}
 }